@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/rlanhellas/aruna/global"
 	"github.com/spf13/viper"
 )
@@ -15,6 +17,99 @@ func HttpServerEnabled() bool {
 	return viper.InConfig(global.HttpServerEnabled) && viper.GetBool(global.HttpServerEnabled)
 }
 
+// HttpOpenAPIExport return the file path where the OpenAPI document is written on startup, empty disables it
+func HttpOpenAPIExport() string {
+	return viper.GetString(global.HttpOpenAPIExport)
+}
+
+// HttpProblemDetails return whether errors are rendered as RFC 7807 application/problem+json
+func HttpProblemDetails() bool {
+	return viper.GetBool(global.HttpProblemDetails)
+}
+
+// HttpProblemTypeBase return the URI prefix of problem types, joined with the error code. Empty uses about:blank
+func HttpProblemTypeBase() string {
+	return viper.GetString(global.HttpProblemTypeBase)
+}
+
+// HttpDebugVars return whether expvar metrics are served at /debug/vars. Default false
+func HttpDebugVars() bool {
+	return viper.GetBool(global.HttpDebugVars)
+}
+
+// HttpShutdownTimeout return how long the http server waits for in-flight requests on shutdown. Default 10s
+func HttpShutdownTimeout() time.Duration {
+	if !viper.IsSet(global.HttpShutdownTimeout) {
+		return 10 * time.Second
+	}
+	return viper.GetDuration(global.HttpShutdownTimeout)
+}
+
+// HttpStreamHeartbeat return the interval of SSE heartbeats and WebSocket pings. Default 15s, also used when not positive
+func HttpStreamHeartbeat() time.Duration {
+	if d := viper.GetDuration(global.HttpStreamHeartbeat); d > 0 {
+		return d
+	}
+	return 15 * time.Second
+}
+
+// HttpStreamBuffer return how many messages are buffered per SSE or WebSocket connection. Default 64, also used when
+// negative
+func HttpStreamBuffer() int {
+	if !viper.IsSet(global.HttpStreamBuffer) || viper.GetInt(global.HttpStreamBuffer) < 0 {
+		return 64
+	}
+	return viper.GetInt(global.HttpStreamBuffer)
+}
+
+// HttpStreamSendTimeout return how long sending waits for a full buffer before dropping a slow consumer. Default 5s,
+// also used when not positive
+func HttpStreamSendTimeout() time.Duration {
+	if d := viper.GetDuration(global.HttpStreamSendWait); d > 0 {
+		return d
+	}
+	return 5 * time.Second
+}
+
+// HttpStreamReadLimit return the max size in bytes of messages received from WebSocket clients, e.g. 64KB. Default 1MB
+func HttpStreamReadLimit() int64 {
+	if size := int64(viper.GetSizeInBytes(global.HttpStreamReadMax)); size > 0 {
+		return size
+	}
+	return 1 << 20
+}
+
+// HttpStreamAllowedOrigins return origins allowed to open WebSocket connections, * allows all. Default same origin
+func HttpStreamAllowedOrigins() []string {
+	return viper.GetStringSlice(global.HttpStreamOrigins)
+}
+
+// HttpUploadMaxBodySize return the max size in bytes of multipart request bodies, e.g. 32MB. Default 32MB
+func HttpUploadMaxBodySize() int64 {
+	if !viper.IsSet(global.HttpUploadMaxBody) {
+		return 32 << 20
+	}
+	return int64(viper.GetSizeInBytes(global.HttpUploadMaxBody))
+}
+
+// HttpUploadMaxFileSize return the max size in bytes of each uploaded file, e.g. 10MB. Default 10MB
+func HttpUploadMaxFileSize() int64 {
+	if !viper.IsSet(global.HttpUploadMaxFile) {
+		return 10 << 20
+	}
+	return int64(viper.GetSizeInBytes(global.HttpUploadMaxFile))
+}
+
+// HttpUploadAllowedTypes return the content types allowed for uploaded files, e.g. image/*. Default all
+func HttpUploadAllowedTypes() []string {
+	return viper.GetStringSlice(global.HttpUploadTypes)
+}
+
+// StorageLocalRoot return the directory where uploads are stored by the local storage, disabled when empty
+func StorageLocalRoot() string {
+	return viper.GetString(global.StorageLocalRoot)
+}
+
 // LoggerLevel return logger level
 func LoggerLevel() string {
 	return viper.GetString(global.LoggerLevel)
@@ -40,12 +135,17 @@ func AppVer() string {
 	return viper.GetString(global.AppVer)
 }
 
+// AppProfile return the active profile (e.g. local, dev, prod)
+func AppProfile() string {
+	return viper.GetString(global.AppProfile)
+}
+
 // DbEnabled return whether db integration is enabled
 func DbEnabled() bool {
 	return viper.InConfig(global.DbEnabled) && viper.GetBool(global.DbEnabled)
 }
 
-// DbType return the db type (postgres, mysql, oracle, sqlserver, etc ...)
+// DbType return the db type (postgres, mysql, sqlserver, sqlite)
 func DbType() string {
 	return viper.GetString(global.DbType)
 }
@@ -65,6 +165,104 @@ func DbSchema() string {
 	return viper.GetString(global.DbSchema)
 }
 
+// DbPoolMaxOpen return max open connections in the pool, zero means unlimited
+func DbPoolMaxOpen() int {
+	return viper.GetInt(global.DbPoolMaxOpen)
+}
+
+// DbPoolMaxIdle return max idle connections in the pool, zero keeps the driver default
+func DbPoolMaxIdle() int {
+	return viper.GetInt(global.DbPoolMaxIdle)
+}
+
+// DbPoolMaxLifetime return max amount of time a connection may be reused (e.g. 30m), zero means forever
+func DbPoolMaxLifetime() time.Duration {
+	return viper.GetDuration(global.DbPoolMaxLifetime)
+}
+
+// DbPoolMaxIdleTime return max amount of time a connection may be idle (e.g. 5m), zero means forever
+func DbPoolMaxIdleTime() time.Duration {
+	return viper.GetDuration(global.DbPoolMaxIdleTime)
+}
+
+// DbConnectRetries return how many times connecting to database is retried on startup
+func DbConnectRetries() int {
+	return viper.GetInt(global.DbConnectRetries)
+}
+
+// DbConnectBackoff return initial wait between connection retries, doubled at each attempt. Default 1s
+func DbConnectBackoff() time.Duration {
+	if !viper.IsSet(global.DbConnectBackoff) {
+		return time.Second
+	}
+	return viper.GetDuration(global.DbConnectBackoff)
+}
+
+// DbTxMaxAttempts return how many times a transaction failing with serialization failure or deadlock is run. Default 1
+func DbTxMaxAttempts() int {
+	if !viper.IsSet(global.DbTxMaxAttempts) {
+		return 1
+	}
+	return viper.GetInt(global.DbTxMaxAttempts)
+}
+
+// DbTxBackoff return initial wait between transaction attempts, doubled at each attempt with jitter. Default 50ms
+func DbTxBackoff() time.Duration {
+	if !viper.IsSet(global.DbTxBackoff) {
+		return 50 * time.Millisecond
+	}
+	return viper.GetDuration(global.DbTxBackoff)
+}
+
+// DbStatsInterval return interval to report connection pool stats, zero disables it
+func DbStatsInterval() time.Duration {
+	return viper.GetDuration(global.DbStatsInterval)
+}
+
+// DbMigrateOnly return whether the app should only run database migrations and exit
+func DbMigrateOnly() bool {
+	return viper.GetBool(global.DbMigrateOnly)
+}
+
+// DbReplicas return the connection strings of read replicas
+func DbReplicas() []string {
+	return viper.GetStringSlice(global.DbReplicas)
+}
+
+// DbReplicaHealthInterval return interval to check replicas health. Default 10s
+func DbReplicaHealthInterval() time.Duration {
+	if !viper.IsSet(global.DbReplicaHealthCheck) {
+		return 10 * time.Second
+	}
+	return viper.GetDuration(global.DbReplicaHealthCheck)
+}
+
+// DbBatchSize return the default batch size of bulk operations. Default 100
+func DbBatchSize() int {
+	if !viper.IsSet(global.DbBatchSize) {
+		return 100
+	}
+	return viper.GetInt(global.DbBatchSize)
+}
+
+// DbAuditEnabled return whether the audit trail of domains is recorded
+func DbAuditEnabled() bool {
+	return viper.GetBool(global.DbAuditEnabled)
+}
+
+// DbSoftDeleteRetention return how long soft deleted rows are kept before being purged, zero keeps them forever
+func DbSoftDeleteRetention() time.Duration {
+	return viper.GetDuration(global.DbSoftDeleteRetain)
+}
+
+// DbSoftDeletePurgeInterval return interval to purge soft deleted rows past retention. Default 1h
+func DbSoftDeletePurgeInterval() time.Duration {
+	if !viper.IsSet(global.DbSoftDeletePurge) {
+		return time.Hour
+	}
+	return viper.GetDuration(global.DbSoftDeletePurge)
+}
+
 // SecurityEnabled return whether security is enabled or not for HTTP calls
 func SecurityEnabled() bool {
 	return viper.InConfig(global.SecurityEnabled) && viper.GetBool(global.SecurityEnabled)
@@ -90,6 +288,112 @@ func SecurityJwkUri() string {
 	return viper.GetString(global.SecurityJwkUri)
 }
 
+// OutboxInterval return interval to dispatch pending outbox messages. Default 1s
+func OutboxInterval() time.Duration {
+	if !viper.IsSet(global.OutboxInterval) {
+		return time.Second
+	}
+	return viper.GetDuration(global.OutboxInterval)
+}
+
+// OutboxBatchSize return max outbox messages read at each dispatch. Default 100
+func OutboxBatchSize() int {
+	if !viper.IsSet(global.OutboxBatchSize) {
+		return 100
+	}
+	return viper.GetInt(global.OutboxBatchSize)
+}
+
+// OutboxDispatchTimeout return how long a replica publishes messages while holding the dispatch lock. Default 30s
+func OutboxDispatchTimeout() time.Duration {
+	if d := viper.GetDuration(global.OutboxDispatchTime); d > 0 {
+		return d
+	}
+	return 30 * time.Second
+}
+
+// OutboxMaxAttempts return how many times a message is published before failing. Default 10
+func OutboxMaxAttempts() int {
+	if !viper.IsSet(global.OutboxMaxAttempts) {
+		return 10
+	}
+	return viper.GetInt(global.OutboxMaxAttempts)
+}
+
+// OutboxBackoff return initial wait to retry a message, doubled at each attempt. Default 1s
+func OutboxBackoff() time.Duration {
+	if !viper.IsSet(global.OutboxBackoff) {
+		return time.Second
+	}
+	return viper.GetDuration(global.OutboxBackoff)
+}
+
+// OutboxBlockOnFailure return whether messages behind a failed one of the same aggregate key stay blocked. Default true
+func OutboxBlockOnFailure() bool {
+	if !viper.IsSet(global.OutboxBlockOnFailure) {
+		return true
+	}
+	return viper.GetBool(global.OutboxBlockOnFailure)
+}
+
+// OutboxRetention return how long delivered messages are kept. Default 24h
+func OutboxRetention() time.Duration {
+	if !viper.IsSet(global.OutboxRetention) {
+		return 24 * time.Hour
+	}
+	return viper.GetDuration(global.OutboxRetention)
+}
+
+// TenantEnabled return whether multi-tenancy is enabled
+func TenantEnabled() bool {
+	return viper.InConfig(global.TenantEnabled) && viper.GetBool(global.TenantEnabled)
+}
+
+// TenantMode return how tenants are isolated (schema or row). Default schema
+func TenantMode() string {
+	if !viper.IsSet(global.TenantMode) {
+		return global.TenantModeSchema
+	}
+	return viper.GetString(global.TenantMode)
+}
+
+// TenantResolver return how tenant is resolved from requests (header, claim or subdomain). Default header
+func TenantResolver() string {
+	if !viper.IsSet(global.TenantResolver) {
+		return "header"
+	}
+	return viper.GetString(global.TenantResolver)
+}
+
+// TenantHeader return the header carrying the tenant. Default X-Tenant-ID
+func TenantHeader() string {
+	if !viper.IsSet(global.TenantHeader) {
+		return "X-Tenant-ID"
+	}
+	return viper.GetString(global.TenantHeader)
+}
+
+// TenantClaim return the JWT claim carrying the tenant. Default tenant
+func TenantClaim() string {
+	if !viper.IsSet(global.TenantClaim) {
+		return "tenant"
+	}
+	return viper.GetString(global.TenantClaim)
+}
+
+// TenantAllowed return the allow-list of known tenants
+func TenantAllowed() []string {
+	return viper.GetStringSlice(global.TenantAllowed)
+}
+
+// SecurityPrincipalClaim return the JWT claim identifying the authenticated principal. Default preferred_username
+func SecurityPrincipalClaim() string {
+	if !viper.IsSet(global.SecurityPrincipal) {
+		return "preferred_username"
+	}
+	return viper.GetString(global.SecurityPrincipal)
+}
+
 // Custom return custom configuration
 func Custom(key string) any {
 	return viper.Get(key)
@@ -0,0 +1,415 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/domain"
+	"github.com/rlanhellas/aruna/global"
+	"github.com/rlanhellas/aruna/security"
+	"github.com/rlanhellas/aruna/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+)
+
+// AuditTrail row recorded for each change of domain.AuditTrailed entities, before and after hold only changed fields
+type AuditTrail struct {
+	Id            uint64 `gorm:"primaryKey"`
+	EntityTable   string `gorm:"index:idx_aruna_audit_entity"`
+	EntityId      string `gorm:"index:idx_aruna_audit_entity"`
+	Action        string
+	Before        string
+	After         string
+	Principal     string
+	Tenant        string
+	CorrelationId string
+	CreatedAt     time.Time
+}
+
+// TableName table storing the audit trail
+func (a *AuditTrail) TableName() string {
+	return "aruna_audit_trail"
+}
+
+// fillCreated fill creation and modification audit fields with the context principal
+func fillCreated(ctx context.Context, d domain.BaseDomain) {
+	now := time.Now()
+	if a, ok := d.(domain.CreationAudited); ok {
+		a.SetCreated(now, security.Principal(ctx))
+	}
+	if a, ok := d.(domain.ModificationAudited); ok {
+		a.SetUpdated(now, security.Principal(ctx))
+	}
+}
+
+// fillUpdated fill modification audit fields with the context principal, returning the columns changed by it
+func fillUpdated(ctx context.Context, d domain.BaseDomain) map[string]any {
+	a, ok := d.(domain.ModificationAudited)
+	if !ok {
+		return nil
+	}
+
+	return changedColumns(ctx, d, func() {
+		a.SetUpdated(time.Now(), security.Principal(ctx))
+	})
+}
+
+// createdColumns return the columns filled by domain.CreationAudited, which must be kept untouched by updates
+func createdColumns(ctx context.Context, d domain.BaseDomain) []string {
+	probe, ok := d.Clone().(domain.CreationAudited)
+	if !ok {
+		return nil
+	}
+
+	columns := make([]string, 0, 2)
+	for column := range changedColumns(ctx, probe.(domain.BaseDomain), func() {
+		probe.SetCreated(time.Unix(1, 0), "aruna")
+	}) {
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// changedColumns run fill and return the columns of d whose value changed
+func changedColumns(ctx context.Context, d domain.BaseDomain, fill func()) map[string]any {
+	stmt := &gorm.Statement{DB: client}
+	if err := stmt.Parse(d); err != nil {
+		fill()
+		return nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(d))
+	before := make(map[string]any, len(stmt.Schema.Fields))
+	for _, f := range stmt.Schema.Fields {
+		if f.DBName != "" {
+			before[f.DBName], _ = f.ValueOf(ctx, rv)
+		}
+	}
+
+	fill()
+
+	changed := map[string]any{}
+	for _, f := range stmt.Schema.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		if v, _ := f.ValueOf(ctx, rv); !reflect.DeepEqual(v, before[f.DBName]) {
+			changed[f.DBName] = v
+		}
+	}
+	return changed
+}
+
+// withAuditTrail run op and record its audit trail in the same transaction when enabled for domain
+func withAuditTrail(ctx context.Context, d domain.BaseDomain, action string, op func(ctx context.Context) *gorm.DB) *gorm.DB {
+	if a, ok := d.(domain.AuditTrailed); !ok || !a.AuditTrail() || !config.DbAuditEnabled() {
+		return op(ctx)
+	}
+
+	var result *gorm.DB
+	err := Transaction(ctx, func(ctx context.Context) error {
+		var before any
+		if action != AuditActionCreate {
+			b := d.Clone()
+			// restored rows are soft deleted before the operation
+			finder := writer(ctx)
+			if action == AuditActionRestore {
+				finder = finder.Unscoped()
+			}
+			if r := finder.Find(b); r.Error == nil && r.RowsAffected > 0 {
+				before = b
+			}
+		}
+
+		result = op(ctx)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		var after any
+		if action != AuditActionDelete {
+			a := d.Clone()
+			if err := writer(ctx).Find(a).Error; err != nil {
+				return err
+			}
+			after = a
+		}
+
+		return recordAuditTrail(ctx, d, action, before, after)
+	})
+
+	if err != nil && result != nil && result.Error == nil {
+		result.AddError(err)
+		result.RowsAffected = 0
+	}
+	return result
+}
+
+// auditedRows rows of a domain keyed by primary key, in the order they were loaded
+type auditedRows struct {
+	keys []string
+	rows map[string]domain.BaseDomain
+}
+
+// domains return the rows in the order they were loaded
+func (r *auditedRows) domains() []domain.BaseDomain {
+	domains := make([]domain.BaseDomain, 0, len(r.keys))
+	for _, key := range r.keys {
+		domains = append(domains, r.rows[key])
+	}
+	return domains
+}
+
+// withBulkAuditTrail run op and record the audit trail of each row it changed in the same transaction when enabled for
+// model. before and after load the rows affected by op, rows only loaded after are created, only before are deleted
+func withBulkAuditTrail(ctx context.Context, model domain.BaseDomain, op func(ctx context.Context) *gorm.DB,
+	before func(ctx context.Context) (*auditedRows, error), after func(ctx context.Context, before *auditedRows) (*auditedRows, error)) *gorm.DB {
+	if a, ok := model.(domain.AuditTrailed); !ok || !a.AuditTrail() || !config.DbAuditEnabled() {
+		return op(ctx)
+	}
+
+	var result *gorm.DB
+	err := Transaction(ctx, func(ctx context.Context) error {
+		beforeRows, err := before(ctx)
+		if err != nil {
+			return err
+		}
+
+		result = op(ctx)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		afterRows, err := after(ctx, beforeRows)
+		if err != nil {
+			return err
+		}
+		return recordBulkAuditTrail(ctx, beforeRows, afterRows)
+	})
+
+	if err != nil && result != nil && result.Error == nil {
+		result.AddError(err)
+		result.RowsAffected = 0
+	}
+	return result
+}
+
+// recordBulkAuditTrail record a create, update or delete for each row of before and after, unchanged rows are skipped
+func recordBulkAuditTrail(ctx context.Context, before, after *auditedRows) error {
+	for _, key := range before.keys {
+		b := before.rows[key]
+		a, ok := after.rows[key]
+		if !ok {
+			if err := recordAuditTrail(ctx, b, AuditActionDelete, b, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		beforeFields, err := auditFields(b)
+		if err != nil {
+			return err
+		}
+		afterFields, err := auditFields(a)
+		if err != nil {
+			return err
+		}
+		if reflect.DeepEqual(beforeFields, afterFields) {
+			continue
+		}
+		if err := recordAuditTrail(ctx, a, AuditActionUpdate, b, a); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range after.keys {
+		if _, ok := before.rows[key]; ok {
+			continue
+		}
+		a := after.rows[key]
+		if err := recordAuditTrail(ctx, a, AuditActionCreate, nil, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findAuditedRows load the rows of model matching query, no rows are loaded when query is nil
+func findAuditedRows(ctx context.Context, model domain.BaseDomain, query func(tx *gorm.DB) *gorm.DB) (*auditedRows, error) {
+	if query == nil {
+		return newAuditedRows(ctx, nil)
+	}
+
+	rows := reflect.New(reflect.SliceOf(reflect.TypeOf(newModel(model))))
+	if err := query(writer(ctx).Model(newModel(model))).Find(rows.Interface()).Error; err != nil {
+		return nil, err
+	}
+	return newAuditedRows(ctx, rows.Interface())
+}
+
+// newAuditedRows key the domains of the slice entities by primary key
+func newAuditedRows(ctx context.Context, entities any) (*auditedRows, error) {
+	result := &auditedRows{rows: map[string]domain.BaseDomain{}}
+	for _, d := range domainsOf(entities) {
+		key, err := primaryKey(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := result.rows[key]; !ok {
+			result.keys = append(result.keys, key)
+		}
+		result.rows[key] = d
+	}
+	return result, nil
+}
+
+// matchingRows return a query matching the rows with the same values of columns as one of entities, columns default
+// to the primary key. nil is returned when there are no entities
+func matchingRows(ctx context.Context, entities []domain.BaseDomain, columns []string) (func(tx *gorm.DB) *gorm.DB, error) {
+	if len(entities) == 0 {
+		return nil, nil
+	}
+
+	stmt := &gorm.Statement{DB: client}
+	if err := stmt.Parse(entities[0]); err != nil {
+		return nil, err
+	}
+	var fields []*schema.Field
+	for _, c := range columns {
+		field := stmt.Schema.LookUpField(c)
+		if field == nil {
+			return nil, fmt.Errorf("unknown column %s of %s", c, stmt.Schema.Table)
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		fields = stmt.Schema.PrimaryFields
+	}
+
+	rows := make([]clause.Expression, 0, len(entities))
+	for _, d := range entities {
+		rv := reflect.Indirect(reflect.ValueOf(d))
+		row := make([]clause.Expression, 0, len(fields))
+		for _, f := range fields {
+			v, _ := f.ValueOf(ctx, rv)
+			row = append(row, clause.Eq{Column: clause.Column{Table: stmt.Schema.Table, Name: f.DBName}, Value: v})
+		}
+		rows = append(rows, clause.And(row...))
+	}
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(clause.Or(rows...))
+	}, nil
+}
+
+// domainsOf return the domains of the slice entities
+func domainsOf(entities any) []domain.BaseDomain {
+	rv := reflect.Indirect(reflect.ValueOf(entities))
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+
+	domains := make([]domain.BaseDomain, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		if item.Kind() != reflect.Ptr && item.CanAddr() {
+			item = item.Addr()
+		}
+		if d, ok := item.Interface().(domain.BaseDomain); ok {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// primaryKey return the values of the primary key fields of d joined by commas
+func primaryKey(ctx context.Context, d domain.BaseDomain) (string, error) {
+	stmt := &gorm.Statement{DB: client}
+	if err := stmt.Parse(d); err != nil {
+		return "", err
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(d))
+	values := make([]string, 0, len(stmt.Schema.PrimaryFields))
+	for _, f := range stmt.Schema.PrimaryFields {
+		v, _ := f.ValueOf(ctx, rv)
+		values = append(values, fmt.Sprint(v))
+	}
+	return strings.Join(values, ","), nil
+}
+
+func recordAuditTrail(ctx context.Context, d domain.BaseDomain, action string, before, after any) error {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range beforeFields {
+		if av, ok := afterFields[k]; ok && reflect.DeepEqual(v, av) {
+			delete(beforeFields, k)
+			delete(afterFields, k)
+		}
+	}
+
+	entityId := ""
+	stmt := &gorm.Statement{DB: client}
+	if err := stmt.Parse(d); err == nil && stmt.Schema.PrioritizedPrimaryField != nil {
+		id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(ctx, reflect.Indirect(reflect.ValueOf(d)))
+		entityId = fmt.Sprint(id)
+	}
+
+	correlationId, _ := ctx.Value(global.CorrelationID).(string)
+	trail := &AuditTrail{
+		EntityTable:   d.TableName(),
+		EntityId:      entityId,
+		Action:        action,
+		Before:        auditJson(beforeFields),
+		After:         auditJson(afterFields),
+		Principal:     security.Principal(ctx),
+		Tenant:        tenant.FromContext(ctx),
+		CorrelationId: correlationId,
+		CreatedAt:     time.Now(),
+	}
+	return writer(ctx).Create(trail).Error
+}
+
+func auditFields(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]any{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func auditJson(fields map[string]any) string {
+	if fields == nil {
+		return ""
+	}
+	b, _ := json.Marshal(fields)
+	return string(b)
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/domain"
+	"github.com/rlanhellas/aruna/global"
+	"github.com/rlanhellas/aruna/httpbridge"
+	"github.com/rlanhellas/aruna/logger"
+	"github.com/rlanhellas/aruna/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateManyWithBindHandlerHttp create entities in batches and return the response to be used by HTTP handlers
+func CreateManyWithBindHandlerHttp(ctx context.Context, entities any, batchSize int) *httpbridge.HandlerHttpResponse {
+	result := CreateMany(ctx, entities, batchSize)
+	if result.Error != nil {
+		return resolveHandlerResponse(result.Error, http.StatusNotAcceptable, nil)
+	}
+	return resolveHandlerResponse(nil, http.StatusCreated, entities)
+}
+
+// CreateMany create a slice of entities on db using batches of batchSize rows, zero uses db.batchsize config. The audit
+// trail of domain.AuditTrailed entities records a create per row
+func CreateMany(ctx context.Context, entities any, batchSize int) *gorm.DB {
+	logger.Debug(ctx, "creating %d entities of %T", sliceLen(entities), entities)
+	fillCreatedAll(ctx, entities)
+	op := func(ctx context.Context) *gorm.DB {
+		return writer(ctx).CreateInBatches(entities, resolveBatchSize(batchSize))
+	}
+
+	model := sliceModel(entities)
+	if model == nil {
+		return op(ctx)
+	}
+	return withBulkAuditTrail(ctx, model, op, func(ctx context.Context) (*auditedRows, error) {
+		return findAuditedRows(ctx, model, nil)
+	}, func(ctx context.Context, _ *auditedRows) (*auditedRows, error) {
+		query, err := matchingRows(ctx, domainsOf(entities), nil)
+		if err != nil {
+			return nil, err
+		}
+		return findAuditedRows(ctx, model, query)
+	})
+}
+
+// ErrUpsertColumnsRequired is returned when tenant scoped entities are upserted without explicit update columns
+var ErrUpsertColumnsRequired = errors.New("update columns are required to upsert tenant scoped entities")
+
+// Upsert create a slice of entities or update them when conflicting on conflictColumns. Only updateColumns are updated
+// on conflict, all columns but the primary key and conflictColumns when it is empty. The tenant and creation audit
+// columns are never updated. Tenant scoped entities require updateColumns and only rows of the context tenant are
+// updated, which is supported by postgres and sqlite. The audit trail of domain.AuditTrailed entities records a create
+// or an update per changed row
+func Upsert(ctx context.Context, entities any, conflictColumns []string, updateColumns []string, batchSize int) *gorm.DB {
+	logger.Debug(ctx, "upserting %d entities of %T, conflict columns %v, update columns %v", sliceLen(entities), entities, conflictColumns, updateColumns)
+	fillCreatedAll(ctx, entities)
+
+	tx := writer(ctx)
+	onConflict := clause.OnConflict{}
+	for _, c := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: c})
+	}
+
+	model := sliceModel(entities)
+	stmt := &gorm.Statement{DB: tx}
+	if model == nil || stmt.Parse(model) != nil {
+		tx.AddError(fmt.Errorf("can not upsert %T, entities must be a slice of domains", entities))
+		return tx
+	}
+
+	protected := map[string]bool{}
+	for _, c := range createdColumns(ctx, model) {
+		protected[c] = true
+	}
+
+	if scoped, ok := model.(domain.TenantScoped); ok {
+		tenantColumn := scoped.TenantColumn()
+		if field := stmt.Schema.LookUpField(tenantColumn); field != nil {
+			tenantColumn = field.DBName
+		}
+		protected[tenantColumn] = true
+
+		if bypass, _ := ctx.Value(global.TenantBypass).(bool); !bypass {
+			if len(updateColumns) == 0 {
+				tx.AddError(ErrUpsertColumnsRequired)
+				return tx
+			}
+			if dialect.Name() != global.PostgresDBType && dialect.Name() != global.SQLiteDBType {
+				tx.AddError(fmt.Errorf("upsert of tenant scoped entities. %w", ErrUnsupportedByDialect))
+				return tx
+			}
+			tenantID := tenant.FromContext(ctx)
+			if tenantID == "" {
+				tx.AddError(ErrTenantRequired)
+				return tx
+			}
+			// a conflicting row of another tenant is left untouched instead of being moved to the context tenant
+			onConflict.Where = clause.Where{Exprs: []clause.Expression{
+				clause.Eq{Column: clause.Column{Table: stmt.Schema.Table, Name: tenantColumn}, Value: tenantID},
+			}}
+		}
+	}
+
+	if len(updateColumns) == 0 {
+		for _, c := range conflictColumns {
+			protected[c] = true
+		}
+		for _, f := range stmt.Schema.Fields {
+			if f.DBName != "" && !f.PrimaryKey && !protected[f.DBName] {
+				updateColumns = append(updateColumns, f.DBName)
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(updateColumns))
+	for _, c := range updateColumns {
+		if !protected[c] {
+			columns = append(columns, c)
+		}
+	}
+	if len(columns) > 0 {
+		onConflict.DoUpdates = clause.AssignmentColumns(columns)
+	} else {
+		onConflict.DoNothing = true
+	}
+
+	// rows are matched by conflict columns before and after, since updated rows keep their key but not inserted ones
+	find := func(ctx context.Context) (*auditedRows, error) {
+		query, err := matchingRows(ctx, domainsOf(entities), conflictColumns)
+		if err != nil {
+			return nil, err
+		}
+		return findAuditedRows(ctx, model, query)
+	}
+	return withBulkAuditTrail(ctx, model, func(ctx context.Context) *gorm.DB {
+		return writer(ctx).Clauses(onConflict).CreateInBatches(entities, resolveBatchSize(batchSize))
+	}, find, func(ctx context.Context, _ *auditedRows) (*auditedRows, error) {
+		return find(ctx)
+	})
+}
+
+// sliceModel return a zero value of the element type of entities, nil when it is not a slice of domains
+func sliceModel(entities any) domain.BaseDomain {
+	t := reflect.TypeOf(entities)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
+		return nil
+	}
+
+	elem := t.Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	model, _ := reflect.New(elem).Interface().(domain.BaseDomain)
+	return model
+}
+
+// UpdateWhere update attributes of all rows of model matching where, returning the affected row count. The audit trail
+// of domain.AuditTrailed models records an update per row
+func UpdateWhere(ctx context.Context, model domain.BaseDomain, where []string, whereArgs []any, attributes map[string]interface{}) (int64, error) {
+	logger.Debug(ctx, "updating attributes %v of entity[%s] where [%v], whereArgs[%v]", attributes, model.TableName(), where, whereArgs)
+	if len(where) == 0 {
+		return 0, errors.New("where is required to update in bulk")
+	}
+	if len(where) != len(whereArgs) {
+		return 0, ErrWhereArgsMismatch
+	}
+
+	values := make(map[string]interface{}, len(attributes)+2)
+	for column, value := range fillUpdated(ctx, newModel(model)) {
+		values[column] = value
+	}
+	for column, value := range attributes {
+		values[column] = value
+	}
+	if v, ok := model.(domain.Versioned); ok {
+		values[v.VersionColumn()] = gorm.Expr(fmt.Sprintf("%s + 1", v.VersionColumn()))
+	}
+
+	scope := whereScope(where, whereArgs)
+	result := withBulkAuditTrail(ctx, model, func(ctx context.Context) *gorm.DB {
+		return scope(writer(ctx).Model(newModel(model))).Updates(values)
+	}, func(ctx context.Context) (*auditedRows, error) {
+		return findAuditedRows(ctx, model, scope)
+	}, func(ctx context.Context, before *auditedRows) (*auditedRows, error) {
+		// updated rows may no longer match where, so they are reloaded by primary key
+		query, err := matchingRows(ctx, before.domains(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return findAuditedRows(ctx, model, query)
+	})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteWhere delete all rows of model matching where, returning the affected row count. The audit trail of
+// domain.AuditTrailed models records a delete per row
+func DeleteWhere(ctx context.Context, model domain.BaseDomain, where []string, whereArgs []any) (int64, error) {
+	logger.Debug(ctx, "deleting entity[%s] where [%v], whereArgs[%v]", model.TableName(), where, whereArgs)
+	if len(where) == 0 {
+		return 0, errors.New("where is required to delete in bulk")
+	}
+	if len(where) != len(whereArgs) {
+		return 0, ErrWhereArgsMismatch
+	}
+
+	scope := whereScope(where, whereArgs)
+	result := withBulkAuditTrail(ctx, model, func(ctx context.Context) *gorm.DB {
+		return scope(writer(ctx)).Delete(newModel(model))
+	}, func(ctx context.Context) (*auditedRows, error) {
+		return findAuditedRows(ctx, model, scope)
+	}, func(ctx context.Context, _ *auditedRows) (*auditedRows, error) {
+		return findAuditedRows(ctx, model, nil)
+	})
+	return result.RowsAffected, result.Error
+}
+
+// whereScope return a scope adding each where condition with its argument
+func whereScope(where []string, whereArgs []any) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		for i, w := range where {
+			tx = tx.Where(w, whereArgs[i])
+		}
+		return tx
+	}
+}
+
+// newModel return a zero value of model type, so primary key values never restrict bulk operations
+func newModel(model domain.BaseDomain) domain.BaseDomain {
+	return reflect.New(reflect.Indirect(reflect.ValueOf(model)).Type()).Interface().(domain.BaseDomain)
+}
+
+func fillCreatedAll(ctx context.Context, entities any) {
+	for _, d := range domainsOf(entities) {
+		fillCreated(ctx, d)
+	}
+}
+
+func resolveBatchSize(batchSize int) int {
+	if batchSize > 0 {
+		return batchSize
+	}
+	return config.DbBatchSize()
+}
+
+func sliceLen(entities any) int {
+	rv := reflect.Indirect(reflect.ValueOf(entities))
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return 0
+	}
+	return rv.Len()
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rlanhellas/aruna/global"
+	"github.com/rlanhellas/aruna/tenant"
+	"github.com/spf13/viper"
+)
+
+func TestUpsertUpdatesConflictingRows(t *testing.T) {
+	setupTestDB(t, &tenantItem{})
+	a := tenant.WithTenant(context.Background(), "a")
+
+	if err := Upsert(a, &[]*tenantItem{{Id: 1, Name: "one"}, {Id: 2, Name: "two"}}, []string{"id"}, []string{"name"}, 0).Error; err != nil {
+		t.Fatal(err)
+	}
+	created := storedTenantItem(t, 1).CreatedAt
+
+	time.Sleep(10 * time.Millisecond)
+	items := &[]*tenantItem{{Id: 1, Name: "changed"}, {Id: 3, Name: "three"}}
+	// creation columns are ignored even when asked for
+	if err := Upsert(a, items, []string{"id"}, []string{"name", "created_at", "updated_at"}, 0).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	stored := storedTenantItem(t, 1)
+	if stored.Name != "changed" {
+		t.Fatalf("name %s, expected changed", stored.Name)
+	}
+	if !stored.CreatedAt.Equal(created) {
+		t.Fatalf("created at changed from %v to %v", created, stored.CreatedAt)
+	}
+	if !stored.UpdatedAt.After(created) {
+		t.Fatalf("updated at %v not after %v", stored.UpdatedAt, created)
+	}
+	if got := storedTenantItem(t, 3).Tenant; got != "a" {
+		t.Fatalf("tenant %s, expected a", got)
+	}
+}
+
+func TestUpsertKeepsRowsOfOtherTenants(t *testing.T) {
+	setupTestDB(t, &tenantItem{})
+	a := tenant.WithTenant(context.Background(), "a")
+	b := tenant.WithTenant(context.Background(), "b")
+
+	if err := Create(a, &tenantItem{Id: 1, Name: "one"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Upsert(b, &[]*tenantItem{{Id: 1, Name: "evil"}}, []string{"id"}, nil, 0).Error; !errors.Is(err, ErrUpsertColumnsRequired) {
+		t.Fatalf("error %v, expected ErrUpsertColumnsRequired", err)
+	}
+	if err := Upsert(b, &[]*tenantItem{{Id: 1, Name: "evil"}}, []string{"id"}, []string{"name", "tenant"}, 0).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	stored := storedTenantItem(t, 1)
+	if stored.Tenant != "a" || stored.Name != "one" {
+		t.Fatalf("row of tenant a changed to tenant %s, name %s", stored.Tenant, stored.Name)
+	}
+}
+
+func TestBulkRequiresMatchingWhereArgs(t *testing.T) {
+	setupTestDB(t, &cursorItem{})
+	ctx := context.Background()
+
+	if _, err := UpdateWhere(ctx, &cursorItem{}, []string{"id = ?"}, nil, map[string]interface{}{"rank": 1}); !errors.Is(err, ErrWhereArgsMismatch) {
+		t.Fatalf("error %v, expected ErrWhereArgsMismatch", err)
+	}
+	if _, err := DeleteWhere(ctx, &cursorItem{}, []string{"id = ?", "rank = ?"}, []any{1}); !errors.Is(err, ErrWhereArgsMismatch) {
+		t.Fatalf("error %v, expected ErrWhereArgsMismatch", err)
+	}
+}
+
+type auditedItem struct {
+	Id   int64 `gorm:"primaryKey"`
+	Name string
+}
+
+func (i *auditedItem) TableName() string {
+	return "audited_items"
+}
+
+func (i *auditedItem) Clone() any {
+	clone := *i
+	return &clone
+}
+
+func (i *auditedItem) AuditTrail() bool {
+	return true
+}
+
+func TestBulkOperationsRecordAuditTrailPerRow(t *testing.T) {
+	setupTestDB(t, &auditedItem{}, &AuditTrail{})
+	viper.Set(global.DbAuditEnabled, true)
+	ctx := context.Background()
+
+	if err := CreateMany(ctx, &[]*auditedItem{{Id: 1, Name: "one"}, {Id: 2, Name: "two"}}, 0).Error; err != nil {
+		t.Fatal(err)
+	}
+	// row 2 is upserted unchanged, so it is not recorded
+	if err := Upsert(ctx, &[]*auditedItem{{Id: 1, Name: "changed"}, {Id: 2, Name: "two"}, {Id: 3, Name: "three"}}, []string{"id"}, []string{"name"}, 0).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpdateWhere(ctx, &auditedItem{}, []string{"name = ?"}, []any{"three"}, map[string]interface{}{"name": "renamed"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeleteWhere(ctx, &auditedItem{}, []string{"id > ?"}, []any{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var trails []AuditTrail
+	if err := client.Order("id").Find(&trails).Error; err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, trail := range trails {
+		got = append(got, trail.Action+" "+trail.EntityId)
+	}
+	expected := "create 1,create 2,update 1,create 3,update 3,delete 2,delete 3"
+	if strings.Join(got, ",") != expected {
+		t.Fatalf("audit trail %v, expected %s", got, expected)
+	}
+	if trails[4].Before != `{"Name":"three"}` || trails[4].After != `{"Name":"renamed"}` {
+		t.Fatalf("update trail before %s, after %s", trails[4].Before, trails[4].After)
+	}
+}
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/rlanhellas/aruna/domain"
+	"github.com/rlanhellas/aruna/httpbridge"
+	"github.com/rlanhellas/aruna/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrInvalidCursor is returned when the cursor token can not be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidPageSize is returned when the page size is lower than one
+	ErrInvalidPageSize = errors.New("page size must be greater than zero")
+	// ErrUnknownSortColumn is returned when the sort column is not a field of the entity
+	ErrUnknownSortColumn = errors.New("unknown sort column")
+	// ErrWhereArgsMismatch is returned when where conditions and whereArgs have different lengths
+	ErrWhereArgsMismatch = errors.New("where and whereArgs must have the same length")
+)
+
+// CursorPageable page returned by keyset (cursor) based listing
+type CursorPageable struct {
+	Content          any    `json:"content"`
+	Size             int    `json:"size"`
+	NumberOfElements int    `json:"numberOfElements"`
+	NextCursor       string `json:"nextCursor,omitempty"`
+	PrevCursor       string `json:"prevCursor,omitempty"`
+	TotalElements    *int64 `json:"totalElements,omitempty"`
+	Empty            bool   `json:"empty"`
+}
+
+// CursorRequest contains the parameters used by ListCursor
+type CursorRequest struct {
+	Where     []string
+	WhereArgs []any
+	// SortColumn column used as keyset, the primary key is always used as tie-breaker. Default is the primary key
+	SortColumn string
+	Desc       bool
+	// Cursor opaque token returned as nextCursor/prevCursor by a previous call, empty means first page
+	Cursor   string
+	PageSize int
+	// WithCount run the count query to fill totalElements, skipped by default
+	WithCount bool
+}
+
+// cursorToken content encoded inside the opaque cursor
+type cursorToken struct {
+	Key      json.RawMessage `json:"k"`
+	Id       json.RawMessage `json:"i"`
+	Backward bool            `json:"b,omitempty"`
+}
+
+// ListCursorWithBindHandlerHttp list entities using keyset pagination and return response to be used by HTTP handlers
+func ListCursorWithBindHandlerHttp(ctx context.Context, req *CursorRequest, domain domain.BaseDomain, results any) *httpbridge.HandlerHttpResponse {
+	r, err := ListCursor(ctx, req, domain, results)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) || errors.Is(err, ErrInvalidPageSize) || errors.Is(err, ErrUnknownSortColumn) {
+			return httpbridge.NewHandlerHttpResponse(err, http.StatusBadRequest, nil)
+		}
+		return resolveHandlerResponse(err, http.StatusInternalServerError, nil)
+	}
+
+	if r.Empty {
+		return resolveHandlerResponse(nil, http.StatusNoContent, nil)
+	}
+	return resolveHandlerResponse(nil, http.StatusOK, r)
+}
+
+// ListCursor list entities using keyset pagination, results must be a pointer to slice
+func ListCursor(ctx context.Context, req *CursorRequest, domain domain.BaseDomain, results any) (*CursorPageable, error) {
+	logger.Debug(ctx, "listing by cursor based on where [%v], whereArgs[%v], sort[%s], desc[%t], pageSize[%d], domain[%s]",
+		req.Where, req.WhereArgs, req.SortColumn, req.Desc, req.PageSize, domain.TableName())
+
+	if req.PageSize <= 0 {
+		return nil, ErrInvalidPageSize
+	}
+	if len(req.Where) != len(req.WhereArgs) {
+		return nil, ErrWhereArgsMismatch
+	}
+
+	stmt := &gorm.Statement{DB: client}
+	if err := stmt.Parse(domain); err != nil {
+		return nil, err
+	}
+
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, fmt.Errorf("entity[%s] has no primary key to be used as cursor", domain.TableName())
+	}
+
+	sortField := pk
+	if req.SortColumn != "" {
+		sortField = stmt.Schema.LookUpField(req.SortColumn)
+		if sortField == nil {
+			return nil, fmt.Errorf("%w %s", ErrUnknownSortColumn, req.SortColumn)
+		}
+	}
+
+	var token *cursorToken
+	var key, id any
+	if req.Cursor != "" {
+		t, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = cursorValue(t.Key, sortField); err != nil {
+			return nil, err
+		}
+		if id, err = cursorValue(t.Id, pk); err != nil {
+			return nil, err
+		}
+		token = t
+	}
+
+	txDB := reader(ctx).Model(domain)
+	for i, whereName := range req.Where {
+		txDB = txDB.Where(whereName, req.WhereArgs[i])
+	}
+
+	var totalElements *int64
+	if req.WithCount {
+		count := int64(0)
+		if err := txDB.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		totalElements = &count
+	}
+
+	backward := token != nil && token.Backward
+	// walking backward means reading in the opposite direction and reversing the page afterwards
+	desc := req.Desc != backward
+	op, dir := ">", "ASC"
+	if desc {
+		op, dir = "<", "DESC"
+	}
+
+	if token != nil {
+		if sortField == pk {
+			txDB = txDB.Where(fmt.Sprintf("%s %s ?", pk.DBName, op), id)
+		} else {
+			txDB = txDB.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", sortField.DBName, op, sortField.DBName, pk.DBName, op),
+				key, key, id)
+		}
+	}
+
+	if sortField != pk {
+		txDB = txDB.Order(fmt.Sprintf("%s %s", sortField.DBName, dir))
+	}
+	txDB = txDB.Order(fmt.Sprintf("%s %s", pk.DBName, dir))
+
+	if err := txDB.Limit(req.PageSize + 1).Find(results).Error; err != nil {
+		return nil, err
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(results))
+	if rv.Kind() != reflect.Slice {
+		return nil, errors.New("results must be a pointer to slice")
+	}
+
+	hasMore := rv.Len() > req.PageSize
+	if hasMore {
+		rv.Set(rv.Slice(0, req.PageSize))
+	}
+	if backward {
+		reverseSlice(rv)
+	}
+
+	page := &CursorPageable{
+		Content:          results,
+		Size:             req.PageSize,
+		NumberOfElements: rv.Len(),
+		TotalElements:    totalElements,
+		Empty:            rv.Len() == 0,
+	}
+
+	if rv.Len() == 0 {
+		return page, nil
+	}
+
+	if hasMore || backward {
+		next, err := encodeCursor(ctx, rv.Index(rv.Len()-1), sortField.DBName, stmt, false)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+
+	if (hasMore && backward) || (token != nil && !backward) {
+		prev, err := encodeCursor(ctx, rv.Index(0), sortField.DBName, stmt, true)
+		if err != nil {
+			return nil, err
+		}
+		page.PrevCursor = prev
+	}
+
+	return page, nil
+}
+
+func encodeCursor(ctx context.Context, item reflect.Value, sortColumn string, stmt *gorm.Statement, backward bool) (string, error) {
+	item = reflect.Indirect(item)
+	key, _ := stmt.Schema.LookUpField(sortColumn).ValueOf(ctx, item)
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(ctx, item)
+
+	kb, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	ib, err := json.Marshal(id)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(&cursorToken{Key: kb, Id: ib, Backward: backward})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(cursor string) (*cursorToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	token := &cursorToken{}
+	if err := json.Unmarshal(b, token); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return token, nil
+}
+
+// cursorValue decode raw into the go type of field, so keys like time.Time are compared as such instead of as text
+func cursorValue(raw json.RawMessage, field *schema.Field) (any, error) {
+	v := reflect.New(field.FieldType)
+	if err := json.Unmarshal(raw, v.Interface()); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return v.Elem().Interface(), nil
+}
+
+func reverseSlice(rv reflect.Value) {
+	swap := reflect.Swapper(rv.Interface())
+	for i, j := 0, rv.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type cursorItem struct {
+	Id   int64 `gorm:"primaryKey"`
+	Rank int
+	At   time.Time
+}
+
+func (c *cursorItem) TableName() string {
+	return "cursor_items"
+}
+
+func (c *cursorItem) Clone() any {
+	clone := *c
+	return &clone
+}
+
+func seedCursorItems(t *testing.T, ranks ...int) {
+	t.Helper()
+	for i, rank := range ranks {
+		if err := Create(context.Background(), &cursorItem{Id: int64(i + 1), Rank: rank}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func cursorIds(items []*cursorItem) []int64 {
+	ids := make([]int64, 0, len(items))
+	for _, i := range items {
+		ids = append(ids, i.Id)
+	}
+	return ids
+}
+
+func TestListCursorWalksForwardAndBackward(t *testing.T) {
+	setupTestDB(t, &cursorItem{})
+	// ties on rank are broken by id
+	seedCursorItems(t, 30, 10, 20, 10, 30, 20, 10)
+	ctx := context.Background()
+
+	var pages [][]int64
+	var cursors []string
+	req := &CursorRequest{SortColumn: "rank", PageSize: 3}
+	for {
+		var items []*cursorItem
+		page, err := ListCursor(ctx, req, &cursorItem{}, &items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, cursorIds(items))
+		cursors = append(cursors, page.PrevCursor)
+		if page.NextCursor == "" {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+
+	expected := [][]int64{{2, 4, 7}, {3, 6, 1}, {5}}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Fatalf("forward pages %v, expected %v", pages, expected)
+	}
+	if cursors[0] != "" {
+		t.Fatalf("first page must not have prev cursor")
+	}
+
+	var items []*cursorItem
+	page, err := ListCursor(ctx, &CursorRequest{SortColumn: "rank", PageSize: 3, Cursor: cursors[2]}, &cursorItem{}, &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := cursorIds(items); !reflect.DeepEqual(ids, expected[1]) {
+		t.Fatalf("backward page %v, expected %v", ids, expected[1])
+	}
+	if page.NextCursor == "" || page.PrevCursor == "" {
+		t.Fatalf("backward page in the middle must have next and prev cursors")
+	}
+}
+
+func TestListCursorWalksTimeColumn(t *testing.T) {
+	setupTestDB(t, &cursorItem{})
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i, minute := range []int{2, 0, 1, 2, 3} {
+		item := &cursorItem{Id: int64(i + 1), At: base.Add(time.Duration(minute) * time.Minute)}
+		if err := Create(context.Background(), item).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []int64
+	req := &CursorRequest{SortColumn: "at", PageSize: 2}
+	for {
+		var items []*cursorItem
+		page, err := ListCursor(context.Background(), req, &cursorItem{}, &items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, cursorIds(items)...)
+		if page.NextCursor == "" {
+			break
+		}
+		req.Cursor = page.NextCursor
+	}
+
+	if expected := []int64{2, 3, 1, 4, 5}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("walked %v, expected %v", ids, expected)
+	}
+}
+
+func TestListCursorDescWithWhereAndCount(t *testing.T) {
+	setupTestDB(t, &cursorItem{})
+	seedCursorItems(t, 30, 10, 20, 10, 30, 20, 10)
+
+	var items []*cursorItem
+	req := &CursorRequest{Where: []string{"rank >= ?"}, WhereArgs: []any{20}, Desc: true, PageSize: 10, WithCount: true}
+	page, err := ListCursor(context.Background(), req, &cursorItem{}, &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := cursorIds(items); !reflect.DeepEqual(ids, []int64{6, 5, 3, 1}) {
+		t.Fatalf("page %v, expected [6 5 3 1]", ids)
+	}
+	if page.TotalElements == nil || *page.TotalElements != 4 {
+		t.Fatalf("total elements %v, expected 4", page.TotalElements)
+	}
+	if page.NextCursor != "" || page.PrevCursor != "" {
+		t.Fatalf("single page must not have cursors")
+	}
+}
+
+func TestListCursorRejectsInvalidRequests(t *testing.T) {
+	setupTestDB(t, &cursorItem{})
+
+	tests := []struct {
+		name string
+		req  *CursorRequest
+		err  error
+	}{
+		{"page size", &CursorRequest{PageSize: 0}, ErrInvalidPageSize},
+		{"sort column", &CursorRequest{PageSize: 1, SortColumn: "missing"}, ErrUnknownSortColumn},
+		{"cursor", &CursorRequest{PageSize: 1, Cursor: "%%%"}, ErrInvalidCursor},
+		{"where args", &CursorRequest{PageSize: 1, Where: []string{"rank = ?", "id = ?"}, WhereArgs: []any{1}}, ErrWhereArgsMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []*cursorItem
+			if _, err := ListCursor(context.Background(), tt.req, &cursorItem{}, &items); !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, expected %v", err, tt.err)
+			}
+		})
+	}
+
+	var items []*cursorItem
+	r := ListCursorWithBindHandlerHttp(context.Background(), &CursorRequest{PageSize: 1, SortColumn: "missing"}, &cursorItem{}, &items)
+	if r.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status %d, expected 400", r.StatusCode)
+	}
+}
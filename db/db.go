@@ -11,7 +11,6 @@ import (
 	"gorm.io/gorm/clause"
 	"net/http"
 	"reflect"
-	"strings"
 )
 
 var client *gorm.DB
@@ -30,6 +29,7 @@ type Pageable struct {
 
 // SetClient configure database client
 func SetClient(c *gorm.DB) {
+	registerCallbacks(c)
 	client = c
 }
 
@@ -45,7 +45,10 @@ func CreateWithBindHandlerHttp(ctx context.Context, domain domain.BaseDomain) *h
 // Create entity on db
 func Create(ctx context.Context, domain domain.BaseDomain) *gorm.DB {
 	logger.Debug(ctx, "creating entity[%s]: %+v", domain.TableName(), domain)
-	return client.Create(domain)
+	fillCreated(ctx, domain)
+	return withAuditTrail(ctx, domain, AuditActionCreate, func(ctx context.Context) *gorm.DB {
+		return writer(ctx).Create(domain)
+	})
 }
 
 // UpdateWithBindHandlerHttp update entity and return the response to be used by HTTP handlers
@@ -71,9 +74,16 @@ func UpdateSpecificAttributesWithBindHandlerHttp(ctx context.Context, domain dom
 // Update entity on db
 func Update(ctx context.Context, domain domain.BaseDomain) *gorm.DB {
 	logger.Debug(ctx, "updating entity[%s]: %+v", domain.TableName(), domain)
-	r, exist := EntityExist(ctx, domain)
+	r, exist := EntityExist(WithPrimary(ctx), domain)
 	if exist {
-		return client.Save(domain)
+		fillUpdated(ctx, domain)
+		return withAuditTrail(ctx, domain, AuditActionUpdate, func(ctx context.Context) *gorm.DB {
+			tx := writer(ctx)
+			if columns := createdColumns(ctx, domain); len(columns) > 0 {
+				tx = tx.Omit(columns...)
+			}
+			return versionedSave(tx, domain)
+		})
 	} else {
 		return r
 	}
@@ -82,9 +92,18 @@ func Update(ctx context.Context, domain domain.BaseDomain) *gorm.DB {
 // UpdateSpecificAttributes specific attributes on table db
 func UpdateSpecificAttributes(ctx context.Context, domain domain.BaseDomain, updateinformation map[string]interface{}) *gorm.DB {
 	logger.Debug(ctx, "updating attributes entity[%s]: %+v", domain.TableName(), domain)
-	r, exist := EntityExist(ctx, domain)
+	r, exist := EntityExist(WithPrimary(ctx), domain)
 	if exist {
-		return client.Model(domain).Updates(updateinformation)
+		attributes := make(map[string]interface{}, len(updateinformation))
+		for column, value := range fillUpdated(ctx, domain) {
+			attributes[column] = value
+		}
+		for column, value := range updateinformation {
+			attributes[column] = value
+		}
+		return withAuditTrail(ctx, domain, AuditActionUpdate, func(ctx context.Context) *gorm.DB {
+			return versionedUpdates(writer(ctx), domain, attributes)
+		})
 	} else {
 		return r
 	}
@@ -103,7 +122,7 @@ func GetByIdWithBindHandlerHttp(ctx context.Context, domain domain.BaseDomain, p
 // EntityExist check if entity exist in db searching by id
 func EntityExist(ctx context.Context, domain domain.BaseDomain) (*gorm.DB, bool) {
 	logger.Debug(ctx, "checking if entity[%s] %+v exists", domain.TableName(), domain)
-	result := client.Find(domain.Clone())
+	result := reader(ctx).Find(domain.Clone())
 	if result.RowsAffected > 0 {
 		return result, true
 	} else {
@@ -116,7 +135,7 @@ func GetById(ctx context.Context, domain domain.BaseDomain, preload []string) (*
 	//logger.Debug(ctx, "getting entity[%s] %+v by id", domain.TableName(), domain)
 	var result *gorm.DB
 	if preload != nil && len(preload) > 0 {
-		tx := client.Preload(preload[0])
+		tx := reader(ctx).Preload(preload[0])
 		for i, p := range preload {
 			if i == 0 {
 				continue
@@ -125,25 +144,24 @@ func GetById(ctx context.Context, domain domain.BaseDomain, preload []string) (*
 		}
 		result = tx.Find(domain)
 	} else {
-		result = client.Preload(clause.Associations).Find(domain)
+		result = reader(ctx).Preload(clause.Associations).Find(domain)
 	}
 	return result, domain
 }
 
 // GetSequenceId Get a ID sequence on database
+//
+// Deprecated: errors are ignored and 0 is returned, use NextSequence instead
 func GetSequenceId(sequenceName string) uint64 {
-	var nextval uint64
-	client.Raw("SELECT nextval(?) as nextval;", sequenceName).Scan(&nextval)
+	nextval, _ := NextSequence(context.Background(), sequenceName)
 	return nextval
 }
 
 // ExecSQL execute a native sql and store the result in dest variable
+//
+// Deprecated: errors are ignored, use Query or Exec instead
 func ExecSQL(sql string, dest any, args ...any) {
-	if args != nil {
-		client.Raw(sql, args).Scan(dest)
-	} else {
-		client.Raw(sql).Scan(dest)
-	}
+	_, _ = Query(WithPrimary(context.Background()), dest, sql, args...)
 }
 
 // DeleteWithBindHandlerHttp delete entity and return the response to be used by HTTP handlers
@@ -159,13 +177,15 @@ func DeleteWithBindHandlerHttp(ctx context.Context, domain domain.BaseDomain) *h
 // Delete entity on db
 func Delete(ctx context.Context, domain domain.BaseDomain) *gorm.DB {
 	logger.Debug(ctx, "deleting entity[%s] %+v", domain.TableName(), domain)
-	return client.Delete(domain)
+	return withAuditTrail(ctx, domain, AuditActionDelete, func(ctx context.Context) *gorm.DB {
+		return writer(ctx).Delete(domain)
+	})
 }
 
 // Delete entity with association on db
 func DeleteWithAssociation(ctx context.Context, domain, target domain.BaseDomain, association string) error {
 	logger.Debug(ctx, "deleting association[%s] in entity %+v", association, domain)
-	return client.Model(domain).Association(association).Delete(target)
+	return writer(ctx).Model(domain).Association(association).Delete(target)
 }
 
 // ListWithBindHandlerHttp entities based on where and return response to be used by HTTP handlers
@@ -212,7 +232,8 @@ func List(ctx context.Context, where []string, orderBy string, whereArgs []strin
 
 	totalElements := int64(0)
 	pageSize64 := int64(pageSize)
-	txDB := client.Model(domain)
+	rdb := reader(ctx)
+	txDB := rdb.Model(domain)
 
 	if len(where) > 0 {
 		for i, whereName := range where {
@@ -255,7 +276,7 @@ func List(ctx context.Context, where []string, orderBy string, whereArgs []strin
 		}, nil, errors.New("Pagination out of correct range.")
 	}
 	offset := (page - 1) * pageSize
-	txDB = client.Offset(offset).Limit(pageSize)
+	txDB = rdb.Offset(offset).Limit(pageSize)
 	if len(where) > 0 {
 		for i, whereName := range where {
 			txDB.Where(whereName, whereArgs[i])
@@ -312,7 +333,8 @@ func resolveHandlerResponse(err error, successStatus int, data any) *httpbridge.
 			}
 		}
 		statusCodeError := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "duplicate key") {
+		var conflict *ConflictError
+		if errors.Is(err, ErrUniqueViolation) || errors.As(err, &conflict) {
 			statusCodeError = http.StatusConflict
 		}
 		return &httpbridge.HandlerHttpResponse{
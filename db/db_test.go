@@ -0,0 +1,45 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rlanhellas/aruna/global"
+	"github.com/rlanhellas/aruna/logger"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// setupTestDB point the package to a new sqlite database in a temp dir with models migrated, restoring the previous
+// client, dialect and config when the test ends
+func setupTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+	logger.SetLogger(zap.NewNop().Sugar())
+
+	d, err := DialectFor(global.SQLiteDBType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := gorm.Open(d.Open(filepath.Join(t.TempDir(), "aruna.db")), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previousClient, previousDialect := client, dialect
+	SetDialect(d)
+	SetClient(c)
+	t.Cleanup(func() {
+		if sqlDB, err := c.DB(); err == nil {
+			sqlDB.Close()
+		}
+		client, dialect = previousClient, previousDialect
+		viper.Reset()
+	})
+
+	if err := c.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
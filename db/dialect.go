@@ -0,0 +1,381 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"reflect"
+	"strings"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/rlanhellas/aruna/global"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+)
+
+const sqliteDriverPkg = "github.com/mattn/go-sqlite3"
+
+// ErrUnsupportedByDialect is returned when the feature does not exist in the configured database
+var ErrUnsupportedByDialect = errors.New("operation not supported by database dialect")
+
+// Dialect abstract the database specific behaviour used by aruna
+type Dialect interface {
+	// Name return the db type handled by this dialect, the same used in db.type config
+	Name() string
+	// Open return the gorm dialector for the given connection string
+	Open(dsn string) gorm.Dialector
+	// WithSchema return the connection string binding every pooled connection to schema
+	WithSchema(dsn, schema string) (string, error)
+	// CreateSchemaSQL return the statement to create schema if it does not exist
+	CreateSchemaSQL(schema string) string
+	// NextSequenceValue return the next value of a database sequence
+	NextSequenceValue(tx *gorm.DB, sequenceName string) (uint64, error)
+	// Lock acquire a session level advisory lock, tx must be bound to a single connection
+	Lock(tx *gorm.DB, name string) error
+	// TryLock acquire the advisory lock like Lock without waiting, acquired is false when it is held by another session
+	TryLock(tx *gorm.DB, name string) (acquired bool, err error)
+	// Unlock release the advisory lock acquired by Lock
+	Unlock(tx *gorm.DB, name string) error
+	// ClassifyError return the kind (ErrUniqueViolation, ErrDatabase...) and constraint of a driver error, ok is false
+	// when err was not raised by the driver
+	ClassifyError(err error) (kind error, constraint string, ok bool)
+}
+
+var dialects = map[string]Dialect{
+	global.PostgresDBType:  &postgresDialect{},
+	global.MySQLDBType:     &mysqlDialect{},
+	global.SQLServerDBType: &sqlserverDialect{},
+	global.SQLiteDBType:    &sqliteDialect{},
+}
+
+var dialect Dialect = &postgresDialect{}
+
+// RegisterDialect register a custom dialect, overriding any dialect with the same name
+func RegisterDialect(d Dialect) {
+	dialects[d.Name()] = d
+}
+
+// DialectFor return the dialect for db type
+func DialectFor(dbType string) (Dialect, error) {
+	d, ok := dialects[dbType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported db type %s", dbType)
+	}
+	return d, nil
+}
+
+// SetDialect configure the dialect used by the db package
+func SetDialect(d Dialect) {
+	dialect = d
+}
+
+// CurrentDialect return the dialect used by the db package
+func CurrentDialect() Dialect {
+	return dialect
+}
+
+type postgresDialect struct{}
+
+func (d *postgresDialect) Name() string {
+	return global.PostgresDBType
+}
+
+func (d *postgresDialect) Open(dsn string) gorm.Dialector {
+	return postgres.Open(dsn)
+}
+
+// WithSchema set search_path to the quoted schema, so mixed case schemas created by CreateSchemaSQL are not folded to
+// lowercase
+func (d *postgresDialect) WithSchema(dsn, schema string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		q.Set("search_path", quotePostgresIdentifier(schema))
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(quotePostgresIdentifier(schema))
+	return fmt.Sprintf("%s search_path='%s'", dsn, value), nil
+}
+
+func (d *postgresDialect) CreateSchemaSQL(schema string) string {
+	return "CREATE SCHEMA IF NOT EXISTS " + quotePostgresIdentifier(schema)
+}
+
+func (d *postgresDialect) NextSequenceValue(tx *gorm.DB, sequenceName string) (uint64, error) {
+	var nextval uint64
+	err := tx.Raw("SELECT nextval(?) as nextval;", sequenceName).Scan(&nextval).Error
+	return nextval, err
+}
+
+func (d *postgresDialect) Lock(tx *gorm.DB, name string) error {
+	return tx.Exec("SELECT pg_advisory_lock(?)", lockKey(name)).Error
+}
+
+func (d *postgresDialect) TryLock(tx *gorm.DB, name string) (bool, error) {
+	var acquired bool
+	err := tx.Raw("SELECT pg_try_advisory_lock(?)", lockKey(name)).Scan(&acquired).Error
+	return acquired, err
+}
+
+func (d *postgresDialect) Unlock(tx *gorm.DB, name string) error {
+	return tx.Exec("SELECT pg_advisory_unlock(?)", lockKey(name)).Error
+}
+
+func (d *postgresDialect) ClassifyError(err error) (error, string, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil, "", false
+	}
+
+	switch pgErr.Code {
+	case "23505":
+		return ErrUniqueViolation, pgErr.ConstraintName, true
+	case "23503":
+		return ErrForeignKeyViolation, pgErr.ConstraintName, true
+	case "23514":
+		return ErrCheckViolation, pgErr.ConstraintName, true
+	case "40001", "40P01":
+		return ErrSerialization, "", true
+	default:
+		return ErrDatabase, pgErr.ConstraintName, true
+	}
+}
+
+type mysqlDialect struct{}
+
+func (d *mysqlDialect) Name() string {
+	return global.MySQLDBType
+}
+
+func (d *mysqlDialect) Open(dsn string) gorm.Dialector {
+	return mysql.Open(dsn)
+}
+
+// WithSchema mysql schemas are databases, so the database name of dsn is replaced
+func (d *mysqlDialect) WithSchema(dsn, schema string) (string, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.DBName = schema
+	return cfg.FormatDSN(), nil
+}
+
+func (d *mysqlDialect) CreateSchemaSQL(schema string) string {
+	return fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", strings.ReplaceAll(schema, "`", "``"))
+}
+
+func (d *mysqlDialect) NextSequenceValue(tx *gorm.DB, sequenceName string) (uint64, error) {
+	return 0, ErrUnsupportedByDialect
+}
+
+func (d *mysqlDialect) Lock(tx *gorm.DB, name string) error {
+	var acquired int
+	if err := tx.Raw("SELECT GET_LOCK(?, -1)", name).Scan(&acquired).Error; err != nil {
+		return err
+	}
+	if acquired != 1 {
+		return fmt.Errorf("can not acquire lock %s", name)
+	}
+	return nil
+}
+
+func (d *mysqlDialect) TryLock(tx *gorm.DB, name string) (bool, error) {
+	var acquired int
+	err := tx.Raw("SELECT GET_LOCK(?, 0)", name).Scan(&acquired).Error
+	return acquired == 1, err
+}
+
+func (d *mysqlDialect) Unlock(tx *gorm.DB, name string) error {
+	return tx.Exec("SELECT RELEASE_LOCK(?)", name).Error
+}
+
+func (d *mysqlDialect) ClassifyError(err error) (error, string, bool) {
+	var myErr *mysqldriver.MySQLError
+	if !errors.As(err, &myErr) {
+		return nil, "", false
+	}
+
+	switch myErr.Number {
+	case 1062:
+		return ErrUniqueViolation, quotedAfter(myErr.Message, "for key '", "'"), true
+	case 1216, 1217, 1451, 1452:
+		return ErrForeignKeyViolation, quotedAfter(myErr.Message, "CONSTRAINT `", "`"), true
+	case 3819:
+		return ErrCheckViolation, quotedAfter(myErr.Message, "Check constraint '", "'"), true
+	case 1213:
+		return ErrSerialization, "", true
+	default:
+		return ErrDatabase, "", true
+	}
+}
+
+type sqlserverDialect struct{}
+
+func (d *sqlserverDialect) Name() string {
+	return global.SQLServerDBType
+}
+
+func (d *sqlserverDialect) Open(dsn string) gorm.Dialector {
+	return sqlserver.Open(dsn)
+}
+
+// WithSchema sql server bind the default schema to the database user, it can not be switched by connection string
+func (d *sqlserverDialect) WithSchema(dsn, schema string) (string, error) {
+	return "", ErrUnsupportedByDialect
+}
+
+func (d *sqlserverDialect) CreateSchemaSQL(schema string) string {
+	name := strings.ReplaceAll(schema, "]", "]]")
+	return fmt.Sprintf("IF SCHEMA_ID('%s') IS NULL EXEC('CREATE SCHEMA [%s]')", strings.ReplaceAll(name, "'", "''"), strings.ReplaceAll(name, "'", "''"))
+}
+
+func (d *sqlserverDialect) NextSequenceValue(tx *gorm.DB, sequenceName string) (uint64, error) {
+	var nextval uint64
+	parts := strings.Split(sequenceName, ".")
+	for i, p := range parts {
+		parts[i] = "[" + strings.ReplaceAll(p, "]", "]]") + "]"
+	}
+	err := tx.Raw(fmt.Sprintf("SELECT NEXT VALUE FOR %s as nextval;", strings.Join(parts, "."))).Scan(&nextval).Error
+	return nextval, err
+}
+
+func (d *sqlserverDialect) Lock(tx *gorm.DB, name string) error {
+	return tx.Exec("EXEC sp_getapplock @Resource = ?, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = -1", name).Error
+}
+
+// TryLock sp_getapplock returns a negative code when the lock is not granted
+func (d *sqlserverDialect) TryLock(tx *gorm.DB, name string) (bool, error) {
+	var code int
+	err := tx.Raw("DECLARE @code int; EXEC @code = sp_getapplock @Resource = ?, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = 0; SELECT @code", name).
+		Scan(&code).Error
+	return code >= 0, err
+}
+
+func (d *sqlserverDialect) Unlock(tx *gorm.DB, name string) error {
+	return tx.Exec("EXEC sp_releaseapplock @Resource = ?, @LockOwner = 'Session'", name).Error
+}
+
+func (d *sqlserverDialect) ClassifyError(err error) (error, string, bool) {
+	var msErr mssql.Error
+	if !errors.As(err, &msErr) {
+		return nil, "", false
+	}
+
+	switch msErr.Number {
+	case 2627:
+		return ErrUniqueViolation, quotedAfter(msErr.Message, "constraint '", "'"), true
+	case 2601:
+		return ErrUniqueViolation, quotedAfter(msErr.Message, "unique index '", "'"), true
+	case 547:
+		// the same number is used for foreign key and check constraints
+		if constraint := quotedAfter(msErr.Message, `FOREIGN KEY constraint "`, `"`); constraint != "" {
+			return ErrForeignKeyViolation, constraint, true
+		}
+		return ErrCheckViolation, quotedAfter(msErr.Message, `CHECK constraint "`, `"`), true
+	case 1205, 3960:
+		return ErrSerialization, "", true
+	default:
+		return ErrDatabase, "", true
+	}
+}
+
+type sqliteDialect struct{}
+
+func (d *sqliteDialect) Name() string {
+	return global.SQLiteDBType
+}
+
+func (d *sqliteDialect) Open(dsn string) gorm.Dialector {
+	return sqlite.Open(dsn)
+}
+
+func (d *sqliteDialect) WithSchema(dsn, schema string) (string, error) {
+	return "", ErrUnsupportedByDialect
+}
+
+func (d *sqliteDialect) CreateSchemaSQL(schema string) string {
+	return ""
+}
+
+func (d *sqliteDialect) NextSequenceValue(tx *gorm.DB, sequenceName string) (uint64, error) {
+	return 0, ErrUnsupportedByDialect
+}
+
+// Lock sqlite is a single file database and serializes writers by itself
+func (d *sqliteDialect) Lock(tx *gorm.DB, name string) error {
+	return nil
+}
+
+func (d *sqliteDialect) TryLock(tx *gorm.DB, name string) (bool, error) {
+	return true, nil
+}
+
+func (d *sqliteDialect) Unlock(tx *gorm.DB, name string) error {
+	return nil
+}
+
+// ClassifyError classify sqlite errors by their message, the cgo only sqlite3.Error type is not used so the package
+// still builds with CGO_ENABLED=0
+func (d *sqliteDialect) ClassifyError(err error) (error, string, bool) {
+	if !isSQLiteError(err) {
+		return nil, "", false
+	}
+
+	// constraint errors are reported as "UNIQUE constraint failed: table.column"
+	msg := err.Error()
+	constraint := ""
+	if i := strings.Index(msg, "constraint failed: "); i >= 0 {
+		constraint = msg[i+len("constraint failed: "):]
+	}
+
+	switch {
+	case strings.Contains(msg, "UNIQUE constraint failed"), strings.Contains(msg, "PRIMARY KEY constraint failed"):
+		return ErrUniqueViolation, constraint, true
+	case strings.Contains(msg, "FOREIGN KEY constraint failed"):
+		return ErrForeignKeyViolation, constraint, true
+	case strings.Contains(msg, "CHECK constraint failed"):
+		return ErrCheckViolation, constraint, true
+	case strings.Contains(msg, "database is locked"), strings.Contains(msg, "database table is locked"):
+		return ErrSerialization, "", true
+	default:
+		return ErrDatabase, "", true
+	}
+}
+
+// isSQLiteError return whether err, or an error it wraps, was raised by the sqlite driver
+func isSQLiteError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		t := reflect.TypeOf(err)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.PkgPath() == sqliteDriverPkg {
+			return true
+		}
+	}
+	return false
+}
+
+// quotePostgresIdentifier quote name as a case preserving identifier
+func quotePostgresIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// lockKey turn a lock name into the numeric key used by postgres advisory locks
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound is returned when the requested entity does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrUniqueViolation is returned when a unique or primary key constraint is violated
+	ErrUniqueViolation = errors.New("unique constraint violation")
+	// ErrForeignKeyViolation is returned when a foreign key constraint is violated
+	ErrForeignKeyViolation = errors.New("foreign key constraint violation")
+	// ErrCheckViolation is returned when a check constraint is violated
+	ErrCheckViolation = errors.New("check constraint violation")
+	// ErrSerialization is returned when the transaction could not be serialized or was chosen as deadlock victim, it can be retried
+	ErrSerialization = errors.New("serialization failure")
+	// ErrDatabase is returned for any other error raised by the database driver
+	ErrDatabase = errors.New("database error")
+)
+
+// ClassifiedError driver error classified by kind, use errors.Is with ErrNotFound, ErrUniqueViolation and so on to check it
+type ClassifiedError struct {
+	Kind       error
+	Constraint string
+	Err        error
+}
+
+func (e *ClassifiedError) Error() string {
+	if e.Err.Error() == e.Kind.Error() {
+		return e.Kind.Error()
+	}
+	if e.Constraint != "" {
+		return fmt.Sprintf("%s on %s. %s", e.Kind.Error(), e.Constraint, e.Err.Error())
+	}
+	return fmt.Sprintf("%s. %s", e.Kind.Error(), e.Err.Error())
+}
+
+func (e *ClassifiedError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode return the HTTP status code for the error kind
+func (e *ClassifiedError) StatusCode() int {
+	switch e.Kind {
+	case ErrNotFound:
+		return http.StatusNotFound
+	case ErrUniqueViolation, ErrForeignKeyViolation, ErrSerialization:
+		return http.StatusConflict
+	case ErrCheckViolation:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ErrorCode return the stable code of the error kind
+func (e *ClassifiedError) ErrorCode() string {
+	switch e.Kind {
+	case ErrNotFound:
+		return "not_found"
+	case ErrUniqueViolation:
+		return "unique_violation"
+	case ErrForeignKeyViolation:
+		return "foreign_key_violation"
+	case ErrCheckViolation:
+		return "check_violation"
+	case ErrSerialization:
+		return "serialization_failure"
+	default:
+		return "database_error"
+	}
+}
+
+// SanitizedMessage return a message safe to be sent to clients, without driver details
+func (e *ClassifiedError) SanitizedMessage() string {
+	switch e.Kind {
+	case ErrNotFound:
+		return "resource not found"
+	case ErrUniqueViolation:
+		return "resource already exists"
+	case ErrForeignKeyViolation:
+		return "resource is referenced by or references another resource"
+	case ErrCheckViolation:
+		return "resource violates a data constraint"
+	case ErrSerialization:
+		return "resource was modified concurrently, try again"
+	default:
+		return "internal database error"
+	}
+}
+
+// ClassifyError classify err using the configured dialect, connection failures and timeouts are ErrDatabase. Other
+// errors not raised by the driver are returned untouched
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &ClassifiedError{Kind: ErrNotFound, Err: err}
+	}
+
+	kind, constraint, ok := dialect.ClassifyError(err)
+	if ok {
+		return &ClassifiedError{Kind: kind, Constraint: constraint, Err: err}
+	}
+
+	// connection failures and timeouts carry hosts and driver details, they must not reach clients as is
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return &ClassifiedError{Kind: ErrDatabase, Err: err}
+	}
+	return err
+}
+
+// classifyErrorCallback replace the statement error by its classified error
+func classifyErrorCallback(tx *gorm.DB) {
+	if tx.Error != nil {
+		tx.Error = ClassifyError(tx.Error)
+	}
+}
+
+// quotedAfter return the text quoted by quote right after prefix in msg, empty when prefix is missing
+func quotedAfter(msg, prefix string, quote string) string {
+	start := strings.Index(msg, prefix)
+	if start < 0 {
+		return ""
+	}
+	rest := msg[start+len(prefix):]
+	end := strings.Index(rest, quote)
+	if end < 0 {
+		return ""
+	}
+	return rest[:end]
+}
@@ -0,0 +1,255 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rlanhellas/aruna/logger"
+	"gorm.io/gorm"
+)
+
+const migrationLockName = "aruna_schema_migration"
+
+// migrationFileRegex match files like 0001_create_users.up.sql or 0001_create_users.down.sql
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration versioned sql migration read from the migrations filesystem
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// SchemaHistory row stored for each applied migration
+type SchemaHistory struct {
+	Version     uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Name        string
+	Checksum    string
+	AppliedAt   time.Time
+	ExecutionMs int64
+}
+
+// TableName table storing applied migrations
+func (s *SchemaHistory) TableName() string {
+	return "aruna_schema_history"
+}
+
+// LoadMigrations read all migrations in the root of fsys ordered by version
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s. %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d used by %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrate apply all pending migrations found in fsys holding an advisory lock, so only one replica migrates at a time
+func Migrate(ctx context.Context, fsys fs.FS) error {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, func(conn *gorm.DB) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if h, ok := applied[m.Version]; ok {
+				if h.Checksum != m.Checksum {
+					return fmt.Errorf("checksum mismatch for applied migration %d_%s", m.Version, m.Name)
+				}
+				continue
+			}
+
+			logger.Info(ctx, "applying migration %d_%s", m.Version, m.Name)
+			start := time.Now()
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&SchemaHistory{
+					Version:     m.Version,
+					Name:        m.Name,
+					Checksum:    m.Checksum,
+					AppliedAt:   time.Now(),
+					ExecutionMs: time.Since(start).Milliseconds(),
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("error applying migration %d_%s. %w", m.Version, m.Name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// MigrateDown revert the last steps applied migrations using their down files
+func MigrateDown(ctx context.Context, fsys fs.FS, steps int) error {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, func(conn *gorm.DB) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+			}
+
+			logger.Info(ctx, "reverting migration %d_%s", m.Version, m.Name)
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaHistory{Version: m.Version}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("error reverting migration %d_%s. %w", m.Version, m.Name, err)
+			}
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// withMigrationLock run fc in a single connection of the context tenant holding the migration advisory lock
+func withMigrationLock(ctx context.Context, fc func(conn *gorm.DB) error) error {
+	return withLock(ctx, migrationLockName, func(conn *gorm.DB) error {
+		if err := conn.AutoMigrate(&SchemaHistory{}); err != nil {
+			return err
+		}
+
+		return fc(conn)
+	})
+}
+
+// withLock run fc in a single connection of the context tenant holding the advisory lock name
+func withLock(ctx context.Context, name string, fc func(conn *gorm.DB) error) error {
+	if tenantID := schemaTenant(ctx); tenantID != "" {
+		name += ":" + tenantID
+	}
+
+	return primary(ctx).Connection(func(conn *gorm.DB) error {
+		if err := dialect.Lock(conn, name); err != nil {
+			return err
+		}
+		defer func() {
+			if err := dialect.Unlock(conn, name); err != nil {
+				logger.Error(ctx, "error releasing lock %s. %s", name, err.Error())
+			}
+		}()
+
+		return fc(conn)
+	})
+}
+
+// withTryLock run fc like withLock when the advisory lock name is free, acquired is false and fc is not run when it
+// is held by another session
+func withTryLock(ctx context.Context, name string, fc func(conn *gorm.DB) error) (acquired bool, err error) {
+	if tenantID := schemaTenant(ctx); tenantID != "" {
+		name += ":" + tenantID
+	}
+
+	err = primary(ctx).Connection(func(conn *gorm.DB) error {
+		ok, err := dialect.TryLock(conn, name)
+		if err != nil || !ok {
+			return err
+		}
+		acquired = true
+		defer func() {
+			if err := dialect.Unlock(conn, name); err != nil {
+				logger.Error(ctx, "error releasing lock %s. %s", name, err.Error())
+			}
+		}()
+
+		return fc(conn)
+	})
+	return acquired, err
+}
+
+func appliedMigrations(conn *gorm.DB) (map[uint64]*SchemaHistory, error) {
+	var history []*SchemaHistory
+	if err := conn.Find(&history).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[uint64]*SchemaHistory, len(history))
+	for _, h := range history {
+		applied[h.Version] = h
+	}
+	return applied, nil
+}
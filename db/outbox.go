@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusDelivered = "delivered"
+	OutboxStatusFailed    = "failed"
+
+	outboxLockName = "aruna_outbox_dispatcher"
+)
+
+// ErrOutboxWithoutTransaction is returned when an outbox message is added outside a transaction
+var ErrOutboxWithoutTransaction = errors.New("outbox messages must be added inside db.Transaction")
+
+// OutboxPublisher deliver outbox messages to the message broker, an error schedules the message to be retried
+type OutboxPublisher interface {
+	Publish(ctx context.Context, msg *OutboxMessage) error
+}
+
+// OutboxMessage event waiting to be published, messages with the same aggregate key are delivered in order
+type OutboxMessage struct {
+	Id            uint64 `gorm:"primaryKey"`
+	AggregateType string
+	AggregateKey  string `gorm:"index"`
+	EventType     string
+	Payload       string
+	Status        string `gorm:"index"`
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	DeliveredAt   *time.Time
+}
+
+// TableName table storing outbox messages
+func (m *OutboxMessage) TableName() string {
+	return "aruna_outbox"
+}
+
+// Clone return a copy of message
+func (m *OutboxMessage) Clone() any {
+	c := *m
+	return &c
+}
+
+// AddOutboxMessage write an event to the outbox in the transaction of ctx, payload is encoded as json
+func AddOutboxMessage(ctx context.Context, aggregateType, aggregateKey, eventType string, payload any) error {
+	if !InTransaction(ctx) {
+		return ErrOutboxWithoutTransaction
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return writer(ctx).Create(&OutboxMessage{
+		AggregateType: aggregateType,
+		AggregateKey:  aggregateKey,
+		EventType:     eventType,
+		Payload:       string(b),
+		Status:        OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}).Error
+}
+
+// StartOutboxDispatcher deliver pending outbox messages to publisher at each interval until ctx is done and clean up
+// delivered messages past retention. An advisory lock ensures a single replica dispatches at a time, the others skip
+// the tick instead of waiting for it
+func StartOutboxDispatcher(ctx context.Context, publisher OutboxPublisher) {
+	ticker := time.NewTicker(config.OutboxInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, dispatchCtx := range tenantContexts(ctx) {
+				acquired, err := withTryLock(dispatchCtx, outboxLockName, func(conn *gorm.DB) error {
+					if err := dispatchOutbox(dispatchCtx, publisher); err != nil {
+						return err
+					}
+					return cleanupOutbox(dispatchCtx)
+				})
+				if err != nil {
+					logger.Error(dispatchCtx, "error dispatching outbox messages. %s", err.Error())
+				} else if !acquired {
+					logger.Debug(dispatchCtx, "outbox dispatch skipped, another replica holds the lock")
+				}
+			}
+		}
+	}
+}
+
+// dispatchOutbox publish the due head message of each aggregate key, repeating while messages are delivered so the
+// next message of a key is sent in the same dispatch. Publishing stops after outbox.dispatchtimeout, so a slow broker
+// does not hold the dispatch lock indefinitely
+func dispatchOutbox(ctx context.Context, publisher OutboxPublisher) error {
+	publishCtx, cancel := context.WithTimeout(ctx, config.OutboxDispatchTimeout())
+	defer cancel()
+
+	for publishCtx.Err() == nil {
+		delivered, err := dispatchOutboxBatch(ctx, publishCtx, publisher)
+		if err != nil || delivered == 0 {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatchOutboxBatch publish with publishCtx one batch of due messages which are the oldest not delivered message of
+// their aggregate key, so a key waiting for a retry never takes the batch of the others. Results are saved with ctx,
+// so they are kept when publishCtx expires
+func dispatchOutboxBatch(ctx, publishCtx context.Context, publisher OutboxPublisher) (delivered int, err error) {
+	// messages behind a failed one stay blocked to keep ordering, unless outbox.blockonfailure is disabled
+	blocking := []string{OutboxStatusPending}
+	if config.OutboxBlockOnFailure() {
+		blocking = append(blocking, OutboxStatusFailed)
+	}
+
+	table := (&OutboxMessage{}).TableName()
+	var msgs []*OutboxMessage
+	err = writer(ctx).
+		Where("status = ? AND next_attempt_at <= ?", OutboxStatusPending, time.Now()).
+		Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s prev WHERE prev.aggregate_key = %s.aggregate_key AND prev.id < %s.id AND prev.status IN ?)", table, table, table), blocking).
+		Order("id").Limit(config.OutboxBatchSize()).Find(&msgs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range msgs {
+		if publishCtx.Err() != nil {
+			break
+		}
+		if err := publisher.Publish(publishCtx, m); err != nil {
+			m.Attempts++
+			m.LastError = err.Error()
+			shift := m.Attempts - 1
+			if shift > 16 {
+				shift = 16
+			}
+			m.NextAttemptAt = time.Now().Add(config.OutboxBackoff() * time.Duration(1<<shift))
+			if m.Attempts >= config.OutboxMaxAttempts() {
+				m.Status = OutboxStatusFailed
+				logger.Error(ctx, "outbox message %d of aggregate %s failed after %d attempts. %s", m.Id, m.AggregateKey, m.Attempts, err.Error())
+			} else {
+				logger.Warn(ctx, "error publishing outbox message %d, attempt %d. %s", m.Id, m.Attempts, err.Error())
+			}
+		} else {
+			deliveredAt := time.Now()
+			m.Status = OutboxStatusDelivered
+			m.DeliveredAt = &deliveredAt
+			delivered++
+		}
+
+		if err := writer(ctx).Select("status", "attempts", "last_error", "next_attempt_at", "delivered_at").Updates(m).Error; err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+// RetryOutboxMessage move a failed message back to pending with its attempts reset, releasing the messages of its
+// aggregate key blocked behind it
+func RetryOutboxMessage(ctx context.Context, id uint64) error {
+	result := writer(ctx).Model(&OutboxMessage{}).
+		Where("id = ? AND status = ?", id, OutboxStatusFailed).
+		Updates(map[string]any{"status": OutboxStatusPending, "attempts": 0, "next_attempt_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func cleanupOutbox(ctx context.Context) error {
+	return writer(ctx).
+		Where("status = ? AND delivered_at < ?", OutboxStatusDelivered, time.Now().Add(-config.OutboxRetention())).
+		Delete(&OutboxMessage{}).Error
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rlanhellas/aruna/global"
+	"github.com/spf13/viper"
+)
+
+// recordingPublisher record published payloads, failing those listed in fail
+type recordingPublisher struct {
+	fail      map[string]bool
+	published []string
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, m *OutboxMessage) error {
+	var payload string
+	if err := json.Unmarshal([]byte(m.Payload), &payload); err != nil {
+		return err
+	}
+	if p.fail[payload] {
+		return errors.New("publish failed")
+	}
+	p.published = append(p.published, payload)
+	return nil
+}
+
+func addOutboxMessages(t *testing.T, messages ...[2]string) {
+	t.Helper()
+	err := Transaction(context.Background(), func(ctx context.Context) error {
+		for _, m := range messages {
+			if err := AddOutboxMessage(ctx, "item", m[0], "changed", m[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func outboxStatuses(t *testing.T) map[string]string {
+	t.Helper()
+	var msgs []*OutboxMessage
+	if err := client.Order("id").Find(&msgs).Error; err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]string{}
+	for _, m := range msgs {
+		var payload string
+		_ = json.Unmarshal([]byte(m.Payload), &payload)
+		statuses[payload] = m.Status
+	}
+	return statuses
+}
+
+func setupOutboxTest(t *testing.T) {
+	setupTestDB(t, &OutboxMessage{})
+	viper.Set(global.OutboxBatchSize, 2)
+	viper.Set(global.OutboxMaxAttempts, 1)
+}
+
+func TestAddOutboxMessageRequiresTransaction(t *testing.T) {
+	setupOutboxTest(t)
+	if err := AddOutboxMessage(context.Background(), "item", "a", "changed", "a1"); !errors.Is(err, ErrOutboxWithoutTransaction) {
+		t.Fatalf("error %v, expected ErrOutboxWithoutTransaction", err)
+	}
+}
+
+func TestDispatchOutboxKeepsKeyOrder(t *testing.T) {
+	setupOutboxTest(t)
+	addOutboxMessages(t, [2]string{"a", "a1"}, [2]string{"a", "a2"}, [2]string{"b", "b1"},
+		[2]string{"b", "b2"}, [2]string{"b", "b3"}, [2]string{"a", "a3"})
+
+	publisher := &recordingPublisher{fail: map[string]bool{"a1": true}}
+	if err := dispatchOutbox(context.Background(), publisher); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed head of a does not starve b, which is fully delivered across batches
+	if expected := []string{"b1", "b2", "b3"}; !reflect.DeepEqual(publisher.published, expected) {
+		t.Fatalf("published %v, expected %v", publisher.published, expected)
+	}
+	statuses := outboxStatuses(t)
+	if statuses["a1"] != OutboxStatusFailed || statuses["a2"] != OutboxStatusPending || statuses["a3"] != OutboxStatusPending {
+		t.Fatalf("statuses %v, expected a1 failed and a2, a3 pending", statuses)
+	}
+
+	var failed OutboxMessage
+	if err := client.Where("status = ?", OutboxStatusFailed).First(&failed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := RetryOutboxMessage(context.Background(), failed.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	publisher = &recordingPublisher{}
+	if err := dispatchOutbox(context.Background(), publisher); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"a1", "a2", "a3"}; !reflect.DeepEqual(publisher.published, expected) {
+		t.Fatalf("published %v after retry, expected %v", publisher.published, expected)
+	}
+
+	if err := RetryOutboxMessage(context.Background(), failed.Id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("error %v retrying a delivered message, expected ErrNotFound", err)
+	}
+}
+
+func TestDispatchOutboxSkipsFailedWhenNotBlocking(t *testing.T) {
+	setupOutboxTest(t)
+	viper.Set(global.OutboxBlockOnFailure, false)
+	addOutboxMessages(t, [2]string{"a", "a1"}, [2]string{"a", "a2"}, [2]string{"a", "a3"})
+
+	publisher := &recordingPublisher{fail: map[string]bool{"a1": true}}
+	// the next messages of a are released once a1 is failed, on the following dispatch
+	for i := 0; i < 2; i++ {
+		if err := dispatchOutbox(context.Background(), publisher); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected := []string{"a2", "a3"}; !reflect.DeepEqual(publisher.published, expected) {
+		t.Fatalf("published %v, expected %v", publisher.published, expected)
+	}
+}
+
+// blockingPublisher never publishes, waiting until ctx is done
+type blockingPublisher struct{}
+
+func (p blockingPublisher) Publish(ctx context.Context, m *OutboxMessage) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestDispatchOutboxStopsAfterDispatchTimeout(t *testing.T) {
+	setupOutboxTest(t)
+	viper.Set(global.OutboxMaxAttempts, 10)
+	viper.Set(global.OutboxDispatchTime, "50ms")
+	addOutboxMessages(t, [2]string{"a", "a1"}, [2]string{"b", "b1"}, [2]string{"c", "c1"})
+
+	start := time.Now()
+	if err := dispatchOutbox(context.Background(), blockingPublisher{}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("dispatch took %s, expected to stop after the dispatch timeout", elapsed)
+	}
+
+	var attempts []int
+	if err := client.Model(&OutboxMessage{}).Where("status = ?", OutboxStatusPending).Order("id").
+		Pluck("attempts", &attempts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{1, 0, 0}; !reflect.DeepEqual(attempts, expected) {
+		t.Fatalf("pending attempts %v, expected %v", attempts, expected)
+	}
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+
+	"github.com/rlanhellas/aruna/logger"
+)
+
+// Raw sql helpers are not filtered by tenant scoped domains and do not fill audit fields, parameters are bound
+// positionally with ? or by name with @name passing sql.Named values or a single map[string]any
+
+// Query run a native select storing the rows in dest, returning how many rows were read. Runs on a read replica
+// unless WithPrimary or a transaction is used
+func Query(ctx context.Context, dest any, sql string, args ...any) (int64, error) {
+	logger.Debug(ctx, "querying native sql %s", sql)
+	result := reader(ctx).Raw(sql, args...).Scan(dest)
+	return result.RowsAffected, result.Error
+}
+
+// Exec run a native statement on primary, returning the affected rows
+func Exec(ctx context.Context, sql string, args ...any) (int64, error) {
+	logger.Debug(ctx, "executing native sql %s", sql)
+	result := writer(ctx).Exec(sql, args...)
+	return result.RowsAffected, result.Error
+}
+
+// QueryStream run a native select scanning row by row into T and calling fn for each one, meant for large result
+// sets which do not fit in memory. Iteration stops at the first error returned by fn
+func QueryStream[T any](ctx context.Context, sql string, args []any, fn func(row *T) error) error {
+	logger.Debug(ctx, "streaming native sql %s", sql)
+	tx := reader(ctx)
+	rows, err := tx.Raw(sql, args...).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := new(T)
+		if err := tx.ScanRows(rows, row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// NextSequence return the next value of a database sequence
+func NextSequence(ctx context.Context, sequenceName string) (uint64, error) {
+	return dialect.NextSequenceValue(writer(ctx), sequenceName)
+}
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/global"
+	"github.com/rlanhellas/aruna/logger"
+	"gorm.io/gorm"
+)
+
+// replica read only database client and its last known health
+type replica struct {
+	client  *gorm.DB
+	healthy atomic.Bool
+}
+
+var replicas []*replica
+var replicaCounter atomic.Uint64
+
+// SetReplicas configure read replica clients used by read operations
+func SetReplicas(clients ...*gorm.DB) {
+	rs := make([]*replica, 0, len(clients))
+	for _, c := range clients {
+		registerCallbacks(c)
+		r := &replica{client: c}
+		r.healthy.Store(true)
+		rs = append(rs, r)
+	}
+	replicas = rs
+}
+
+// WithPrimary return a context forcing read operations to use the primary database
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, global.DbForcePrimary, true)
+}
+
+// TxOptions options of a transaction started by Transaction
+type TxOptions struct {
+	// Isolation level of the transaction, the database default when zero
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// MaxAttempts how many times the whole transaction is run while it fails with ErrSerialization, zero uses
+	// db.transaction.maxattempts config
+	MaxAttempts int
+	// Backoff initial wait between attempts, doubled at each attempt with jitter, zero uses db.transaction.backoff config
+	Backoff time.Duration
+}
+
+// Transaction run fc inside a database transaction on the primary. All db package operations receiving
+// the context passed to fc take part in the transaction, which is rolled back when fc returns an error.
+// Transactions failing with serialization failure or deadlock are run again from the start according to opts,
+// so fc must not have side effects outside the database
+func Transaction(ctx context.Context, fc func(ctx context.Context) error, opts ...*TxOptions) error {
+	if _, ok := ctx.Value(global.DbTransaction).(*gorm.DB); ok {
+		return fc(ctx)
+	}
+
+	opt := &TxOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+	maxAttempts := opt.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = config.DbTxMaxAttempts()
+	}
+	backoff := opt.Backoff
+	if backoff <= 0 {
+		backoff = config.DbTxBackoff()
+	}
+
+	var sqlOpts *sql.TxOptions
+	if opt.Isolation != sql.LevelDefault || opt.ReadOnly {
+		sqlOpts = &sql.TxOptions{Isolation: opt.Isolation, ReadOnly: opt.ReadOnly}
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := ClassifyError(primary(ctx).Transaction(func(tx *gorm.DB) error {
+			return fc(context.WithValue(ctx, global.DbTransaction, tx))
+		}, sqlOpts))
+		if err == nil || !errors.Is(err, ErrSerialization) || attempt >= maxAttempts {
+			return err
+		}
+
+		wait := jitter(backoff, attempt)
+		logger.Warn(ctx, "transaction attempt %d of %d failed, retrying in %s. %s", attempt, maxAttempts, wait, err.Error())
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+var jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+var jitterMu sync.Mutex
+
+// jitter return a random wait between zero and backoff doubled for each previous attempt
+func jitter(backoff time.Duration, attempt int) time.Duration {
+	shift := attempt - 1
+	if shift > 16 {
+		shift = 16
+	}
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return time.Duration(jitterRand.Int63n(int64(backoff)*int64(1<<shift) + 1))
+}
+
+// InTransaction return whether ctx carries an ongoing transaction started by Transaction
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(global.DbTransaction).(*gorm.DB)
+	return ok
+}
+
+// writer return the client to be used by write operations, the ongoing transaction if any
+func writer(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(global.DbTransaction).(*gorm.DB); ok {
+		return tx
+	}
+	return primary(ctx)
+}
+
+// reader return the client to be used by read operations. Reads go to a healthy replica picked by round-robin,
+// unless there is an ongoing transaction, a tenant schema bound to the context, the primary was forced by WithPrimary
+// or no replica is available. Soft deleted rows are only included for contexts created by WithDeleted
+func reader(ctx context.Context) *gorm.DB {
+	return includeDeleted(ctx, readClient(ctx))
+}
+
+func readClient(ctx context.Context) *gorm.DB {
+	if _, ok := ctx.Value(global.DbTransaction).(*gorm.DB); ok {
+		return writer(ctx)
+	}
+	if schemaTenant(ctx) != "" {
+		return writer(ctx)
+	}
+	if force, ok := ctx.Value(global.DbForcePrimary).(bool); ok && force {
+		return writer(ctx)
+	}
+
+	rs := replicas
+	for range rs {
+		r := rs[replicaCounter.Add(1)%uint64(len(rs))]
+		if r.healthy.Load() {
+			return r.client.WithContext(ctx)
+		}
+	}
+
+	return writer(ctx)
+}
+
+// StartReplicaHealthCheck ping replicas at each interval until ctx is done, unhealthy replicas are skipped by reads
+func StartReplicaHealthCheck(ctx context.Context, interval time.Duration) {
+	if len(replicas) == 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for i, r := range replicas {
+				healthy := pingReplica(ctx, r)
+				if healthy != r.healthy.Load() {
+					logger.Warn(ctx, "db replica %d healthy changed to %t", i, healthy)
+				}
+				r.healthy.Store(healthy)
+			}
+		}
+	}
+}
+
+func pingReplica(ctx context.Context, r *replica) bool {
+	sqlDB, err := r.client.DB()
+	if err != nil {
+		return false
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(pingCtx) == nil
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/rlanhellas/aruna/domain"
+	"github.com/rlanhellas/aruna/global"
+	"github.com/rlanhellas/aruna/tenant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTenantRequired is returned when a tenant scoped entity is used without a tenant in context
+var ErrTenantRequired = errors.New("tenant is required to access tenant scoped entities")
+
+// WithoutTenantScope return a privileged context where tenant scoped entities are not filtered by tenant, meant for admin jobs
+func WithoutTenantScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, global.TenantBypass, true)
+}
+
+// registerCallbacks register aruna gorm callbacks in the client
+func registerCallbacks(c *gorm.DB) {
+	c.Callback().Create().Before("gorm:create").Register("aruna:tenant_fill", tenantFillCallback)
+	c.Callback().Query().Before("gorm:query").Register("aruna:tenant_scope", tenantScopeCallback)
+	c.Callback().Row().Before("gorm:row").Register("aruna:tenant_scope", tenantScopeCallback)
+	c.Callback().Update().Before("gorm:update").Register("aruna:tenant_scope", tenantWriteScopeCallback)
+	c.Callback().Delete().Before("gorm:delete").Register("aruna:tenant_scope", tenantWriteScopeCallback)
+	c.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("aruna:classify_error", classifyErrorCallback)
+	c.Callback().Query().After("gorm:after_query").Register("aruna:classify_error", classifyErrorCallback)
+	c.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("aruna:classify_error", classifyErrorCallback)
+	c.Callback().Delete().After("gorm:commit_or_rollback_transaction").Register("aruna:classify_error", classifyErrorCallback)
+	c.Callback().Row().After("gorm:row").Register("aruna:classify_error", classifyErrorCallback)
+	c.Callback().Raw().After("gorm:raw").Register("aruna:classify_error", classifyErrorCallback)
+}
+
+// tenantScoped return the tenant column and tenant to be used by the statement, ok is false when it should not be scoped
+func tenantScoped(tx *gorm.DB) (column string, tenantID string, ok bool) {
+	if tx.Statement.Schema == nil {
+		return "", "", false
+	}
+
+	scoped, isScoped := reflect.New(tx.Statement.Schema.ModelType).Interface().(domain.TenantScoped)
+	if !isScoped {
+		return "", "", false
+	}
+
+	ctx := tx.Statement.Context
+	if bypass, _ := ctx.Value(global.TenantBypass).(bool); bypass {
+		return "", "", false
+	}
+
+	tenantID = tenant.FromContext(ctx)
+	if tenantID == "" {
+		tx.AddError(ErrTenantRequired)
+		return "", "", false
+	}
+
+	return scoped.TenantColumn(), tenantID, true
+}
+
+// tenantFillCallback fill the tenant column of created entities with the context tenant
+func tenantFillCallback(tx *gorm.DB) {
+	column, tenantID, ok := tenantScoped(tx)
+	if !ok {
+		return
+	}
+	tx.Statement.SetColumn(column, tenantID, true)
+}
+
+// tenantScopeCallback filter reads by the context tenant
+func tenantScopeCallback(tx *gorm.DB) {
+	column, tenantID, ok := tenantScoped(tx)
+	if !ok {
+		return
+	}
+	addTenantClause(tx, column, tenantID)
+}
+
+// tenantWriteScopeCallback filter updates and deletes by the context tenant
+func tenantWriteScopeCallback(tx *gorm.DB) {
+	column, tenantID, ok := tenantScoped(tx)
+	if !ok {
+		return
+	}
+
+	// keep gorm protection against updates and deletes without conditions, tenant filter alone is not a condition.
+	// Global updates and deletes are still restricted to the context tenant
+	if !hasConditions(tx) && !tx.Statement.AllowGlobalUpdate {
+		return
+	}
+	addTenantClause(tx, column, tenantID)
+}
+
+func addTenantClause(tx *gorm.DB, column, tenantID string) {
+	if field := tx.Statement.Schema.LookUpField(column); field != nil {
+		column = field.DBName
+	}
+
+	tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: tx.Statement.Table, Name: column}, Value: tenantID},
+	}})
+}
+
+// hasConditions return whether the statement already has where clauses or a primary key to filter by
+func hasConditions(tx *gorm.DB) bool {
+	if _, ok := tx.Statement.Clauses["WHERE"]; ok {
+		return true
+	}
+
+	pk := tx.Statement.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return false
+	}
+
+	rv := tx.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Struct:
+		_, zero := pk.ValueOf(tx.Statement.Context, rv)
+		return !zero
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if _, zero := pk.ValueOf(tx.Statement.Context, reflect.Indirect(rv.Index(i))); !zero {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rlanhellas/aruna/domain"
+	"github.com/rlanhellas/aruna/tenant"
+	"gorm.io/gorm"
+)
+
+type tenantItem struct {
+	Id     int64 `gorm:"primaryKey"`
+	Tenant string
+	Name   string
+	domain.AuditFields
+}
+
+func (i *tenantItem) TableName() string {
+	return "tenant_items"
+}
+
+func (i *tenantItem) Clone() any {
+	clone := *i
+	return &clone
+}
+
+func (i *tenantItem) TenantColumn() string {
+	return "tenant"
+}
+
+// storedTenantItem read the row with id ignoring tenant scope
+func storedTenantItem(t *testing.T, id int64) *tenantItem {
+	t.Helper()
+	item := &tenantItem{}
+	if err := client.WithContext(WithoutTenantScope(context.Background())).First(item, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func TestTenantScopeFillsCreatedEntities(t *testing.T) {
+	setupTestDB(t, &tenantItem{})
+	a := tenant.WithTenant(context.Background(), "a")
+
+	if err := Create(a, &tenantItem{Id: 1, Tenant: "b", Name: "one"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := storedTenantItem(t, 1).Tenant; got != "a" {
+		t.Fatalf("tenant %s, expected a", got)
+	}
+
+	if err := Create(context.Background(), &tenantItem{Id: 2}).Error; !errors.Is(err, ErrTenantRequired) {
+		t.Fatalf("error %v, expected ErrTenantRequired", err)
+	}
+}
+
+func TestTenantScopeFiltersReadsAndWrites(t *testing.T) {
+	setupTestDB(t, &tenantItem{})
+	a := tenant.WithTenant(context.Background(), "a")
+	b := tenant.WithTenant(context.Background(), "b")
+	for _, item := range []struct {
+		ctx context.Context
+		id  int64
+	}{{a, 1}, {a, 2}, {b, 3}} {
+		if err := Create(item.ctx, &tenantItem{Id: item.id, Name: "item"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var items []*tenantItem
+	if _, err := ListCursor(a, &CursorRequest{PageSize: 10}, &tenantItem{}, &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("tenant a listed %d items, expected 2", len(items))
+	}
+
+	if r, _ := GetById(b, &tenantItem{Id: 1}, nil); r.RowsAffected != 0 {
+		t.Fatalf("tenant b read an item of tenant a")
+	}
+
+	affected, err := UpdateWhere(b, &tenantItem{}, []string{"id = ?"}, []any{1}, map[string]interface{}{"name": "changed"})
+	if err != nil || affected != 0 {
+		t.Fatalf("tenant b updated %d items of tenant a. %v", affected, err)
+	}
+	if affected, err := DeleteWhere(b, &tenantItem{}, []string{"id IN ?"}, []any{[]int64{1, 2, 3}}); err != nil || affected != 1 {
+		t.Fatalf("tenant b deleted %d items, expected 1. %v", affected, err)
+	}
+	if got := storedTenantItem(t, 1).Name; got != "item" {
+		t.Fatalf("name %s, expected item", got)
+	}
+
+	var all []*tenantItem
+	if _, err := ListCursor(WithoutTenantScope(context.Background()), &CursorRequest{PageSize: 10}, &tenantItem{}, &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("listed %d items without tenant scope, expected 2", len(all))
+	}
+}
+
+func TestTenantScopeRestrictsGlobalWrites(t *testing.T) {
+	setupTestDB(t, &tenantItem{})
+	a := tenant.WithTenant(context.Background(), "a")
+	b := tenant.WithTenant(context.Background(), "b")
+	for i, ctx := range []context.Context{a, a, b} {
+		if err := Create(ctx, &tenantItem{Id: int64(i + 1), Name: "item"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer(b).Model(&tenantItem{}).Update("name", "changed").Error; !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Fatalf("error %v, expected ErrMissingWhereClause", err)
+	}
+
+	global := writer(b).Session(&gorm.Session{AllowGlobalUpdate: true})
+	if r := global.Model(&tenantItem{}).Update("name", "changed"); r.Error != nil || r.RowsAffected != 1 {
+		t.Fatalf("global update of tenant b changed %d items, expected 1. %v", r.RowsAffected, r.Error)
+	}
+	if r := global.Delete(&tenantItem{}); r.Error != nil || r.RowsAffected != 1 {
+		t.Fatalf("global delete of tenant b removed %d items, expected 1. %v", r.RowsAffected, r.Error)
+	}
+	for _, id := range []int64{1, 2} {
+		if got := storedTenantItem(t, id).Name; got != "item" {
+			t.Fatalf("item %d of tenant a changed to %s", id, got)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/domain"
+	"github.com/rlanhellas/aruna/logger"
+	"github.com/rlanhellas/aruna/tenant"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+const (
+	seedLockName = "aruna_seed"
+
+	// SeedAllProfiles profile making a seed run in every profile, prod included
+	SeedAllProfiles = "*"
+)
+
+// ErrSeedProfilesRequired is returned for seeds without Profiles, so no seed runs in prod by accident
+var ErrSeedProfilesRequired = errors.New("seed profiles are required, use * to run in every profile")
+
+// Seed reference data inserted once after migrations in the listed Profiles, either by Run or by creating the objects
+// listed in the Path file (.json, .yml or .yaml) of Data, decoded as Model. Tenant binds the seed to a tenant, which
+// fills tenant scoped entities in row mode and restricts the seed to the tenant schema in schema mode. Use one seed,
+// with its own Name, per tenant
+type Seed struct {
+	Name     string
+	Profiles []string
+	Tenant   string
+	Run      func(ctx context.Context) error
+	Data     fs.FS
+	Path     string
+	Model    domain.BaseDomain
+}
+
+// SeedHistory row stored for each applied seed
+type SeedHistory struct {
+	Name      string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+// TableName table storing applied seeds
+func (s *SeedHistory) TableName() string {
+	return "aruna_seed_history"
+}
+
+// RunSeeds apply seeds of the active profile not applied yet, each one in its own transaction
+func RunSeeds(ctx context.Context, seeds []*Seed) error {
+	if len(seeds) == 0 {
+		return nil
+	}
+
+	for _, s := range seeds {
+		if len(s.Profiles) == 0 {
+			return fmt.Errorf("error running seed %s. %w", s.Name, ErrSeedProfilesRequired)
+		}
+		if s.Tenant != "" && !tenant.Allowed(s.Tenant) {
+			return fmt.Errorf("error running seed %s. %w", s.Name, tenant.ErrUnknownTenant)
+		}
+	}
+
+	for _, seedCtx := range tenantContexts(ctx) {
+		err := withLock(seedCtx, seedLockName, func(conn *gorm.DB) error {
+			if err := primary(seedCtx).AutoMigrate(&SeedHistory{}); err != nil {
+				return err
+			}
+
+			for _, s := range seeds {
+				if err := runSeed(seedCtx, s); err != nil {
+					return fmt.Errorf("error running seed %s. %w", s.Name, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSeed(ctx context.Context, s *Seed) error {
+	if !activeProfile(s.Profiles) {
+		logger.Debug(ctx, "skipping seed %s, profile %s not in %v", s.Name, config.AppProfile(), s.Profiles)
+		return nil
+	}
+
+	if s.Tenant != "" {
+		// in schema mode ctx is bound to the schema being seeded
+		if current := tenant.FromContext(ctx); current != "" && current != s.Tenant {
+			return nil
+		}
+		ctx = tenant.WithTenant(ctx, s.Tenant)
+	}
+
+	var applied int64
+	if err := writer(ctx).Model(&SeedHistory{}).Where("name = ?", s.Name).Count(&applied).Error; err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	logger.Info(ctx, "applying seed %s", s.Name)
+	return Transaction(ctx, func(ctx context.Context) error {
+		if s.Run != nil {
+			if err := s.Run(ctx); err != nil {
+				return err
+			}
+		}
+
+		if s.Data != nil {
+			entities, err := loadSeedFile(s)
+			if err != nil {
+				return err
+			}
+			if err := CreateMany(ctx, entities, 0).Error; err != nil {
+				return err
+			}
+		}
+
+		return writer(ctx).Create(&SeedHistory{Name: s.Name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// loadSeedFile decode the seed file into a pointer to slice of the seed model
+func loadSeedFile(s *Seed) (any, error) {
+	if s.Model == nil {
+		return nil, errors.New("seed model is required to load seed files")
+	}
+
+	content, err := fs.ReadFile(s.Data, s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(path.Ext(s.Path)) {
+	case ".yml", ".yaml":
+		// yaml is converted to json so domains are decoded by their json tags
+		var items []map[string]any
+		if err := yaml.Unmarshal(content, &items); err != nil {
+			return nil, err
+		}
+		if content, err = json.Marshal(items); err != nil {
+			return nil, err
+		}
+	case ".json":
+	default:
+		return nil, fmt.Errorf("unsupported seed file %s, expected .json, .yml or .yaml", s.Path)
+	}
+
+	modelType := reflect.Indirect(reflect.ValueOf(s.Model)).Type()
+	entities := reflect.New(reflect.SliceOf(reflect.PtrTo(modelType)))
+	if err := json.Unmarshal(content, entities.Interface()); err != nil {
+		return nil, err
+	}
+	return entities.Interface(), nil
+}
+
+func activeProfile(profiles []string) bool {
+	for _, p := range profiles {
+		if p == SeedAllProfiles || p == config.AppProfile() {
+			return true
+		}
+	}
+	return false
+}
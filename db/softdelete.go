@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/rlanhellas/aruna/domain"
+	"github.com/rlanhellas/aruna/global"
+	"github.com/rlanhellas/aruna/httpbridge"
+	"github.com/rlanhellas/aruna/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNotSoftDeletable is returned when restoring an entity without soft delete support
+var ErrNotSoftDeletable = errors.New("entity does not support soft delete")
+
+// ErrPrimaryKeyRequired is returned when restoring an entity without primary key, which would restore the whole table
+var ErrPrimaryKeyRequired = errors.New("primary key is required to restore entity")
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// WithDeleted return a context where read operations include soft deleted rows
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, global.DbIncludeDeleted, true)
+}
+
+// includeDeleted apply WithDeleted option to the read client
+func includeDeleted(ctx context.Context, tx *gorm.DB) *gorm.DB {
+	if include, ok := ctx.Value(global.DbIncludeDeleted).(bool); ok && include {
+		// a new session, so where clauses added by callers never stack on a shared statement
+		return tx.Unscoped().Session(&gorm.Session{})
+	}
+	return tx
+}
+
+// RestoreWithBindHandlerHttp restore soft deleted entity and return the response to be used by HTTP handlers
+func RestoreWithBindHandlerHttp(ctx context.Context, d domain.BaseDomain) *httpbridge.HandlerHttpResponse {
+	result := Restore(ctx, d)
+	if errors.Is(result.Error, ErrPrimaryKeyRequired) {
+		return httpbridge.NewHandlerHttpResponse(result.Error, http.StatusBadRequest, nil)
+	}
+	if result.RowsAffected > 0 {
+		return resolveHandlerResponse(nil, http.StatusOK, nil)
+	} else {
+		return resolveHandlerResponse(result.Error, http.StatusNotFound, nil)
+	}
+}
+
+// Restore soft deleted entity on db, it is identified by its primary key which must be set
+func Restore(ctx context.Context, d domain.BaseDomain) *gorm.DB {
+	logger.Debug(ctx, "restoring entity[%s] %+v", d.TableName(), d)
+	field, err := deletedAtField(d)
+	if err == nil && !hasPrimaryKey(ctx, d) {
+		err = ErrPrimaryKeyRequired
+	}
+	if err != nil {
+		tx := writer(ctx)
+		tx.AddError(err)
+		return tx
+	}
+
+	return withAuditTrail(ctx, d, AuditActionRestore, func(ctx context.Context) *gorm.DB {
+		return writer(ctx).Unscoped().Model(d).Where(fmt.Sprintf("%s IS NOT NULL", field.DBName)).Update(field.DBName, nil)
+	})
+}
+
+// HardDelete delete entity on db even when it supports soft delete
+func HardDelete(ctx context.Context, d domain.BaseDomain) *gorm.DB {
+	logger.Debug(ctx, "hard deleting entity[%s] %+v", d.TableName(), d)
+	return withAuditTrail(ctx, d, AuditActionDelete, func(ctx context.Context) *gorm.DB {
+		return writer(ctx).Unscoped().Delete(d)
+	})
+}
+
+// PurgeDeleted hard delete rows of model soft deleted before the retention, returning how many rows were purged
+func PurgeDeleted(ctx context.Context, model any, retention time.Duration) (int64, error) {
+	field, err := deletedAtField(model)
+	if err != nil {
+		return 0, err
+	}
+
+	result := writer(WithoutTenantScope(ctx)).Unscoped().
+		Where(fmt.Sprintf("%s < ?", field.DBName), time.Now().Add(-retention)).
+		Delete(model)
+	return result.RowsAffected, result.Error
+}
+
+// StartSoftDeletePurge purge soft deleted rows of models older than retention at each interval until ctx is done,
+// models without soft delete support are ignored
+func StartSoftDeletePurge(ctx context.Context, models []any, retention, interval time.Duration) {
+	if retention <= 0 || interval <= 0 {
+		return
+	}
+
+	softDeletable := make([]any, 0, len(models))
+	for _, m := range models {
+		if _, err := deletedAtField(m); err == nil {
+			softDeletable = append(softDeletable, m)
+		}
+	}
+	if len(softDeletable) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, purgeCtx := range tenantContexts(ctx) {
+				for _, m := range softDeletable {
+					purged, err := PurgeDeleted(purgeCtx, m, retention)
+					if err != nil {
+						logger.Error(purgeCtx, "error purging soft deleted rows of %s. %s", reflect.TypeOf(m).String(), err.Error())
+						continue
+					}
+					logger.Debug(purgeCtx, "purged %d soft deleted rows of %s", purged, reflect.TypeOf(m).String())
+				}
+			}
+		}
+	}
+}
+
+// hasPrimaryKey return whether all primary key fields of d are set
+func hasPrimaryKey(ctx context.Context, d domain.BaseDomain) bool {
+	stmt := &gorm.Statement{DB: client}
+	if err := stmt.Parse(d); err != nil || len(stmt.Schema.PrimaryFields) == 0 {
+		return false
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(d))
+	for _, f := range stmt.Schema.PrimaryFields {
+		if _, zero := f.ValueOf(ctx, rv); zero {
+			return false
+		}
+	}
+	return true
+}
+
+func deletedAtField(model any) (*schema.Field, error) {
+	stmt := &gorm.Statement{DB: client}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+
+	for _, f := range stmt.Schema.Fields {
+		if f.FieldType == deletedAtType && f.DBName != "" {
+			return f, nil
+		}
+	}
+	return nil, ErrNotSoftDeletable
+}
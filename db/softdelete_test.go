@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rlanhellas/aruna/domain"
+	"github.com/rlanhellas/aruna/global"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+type deletableItem struct {
+	Id   int64 `gorm:"primaryKey"`
+	Name string
+	domain.SoftDeleteField
+}
+
+func (i *deletableItem) TableName() string {
+	return "deletable_items"
+}
+
+func (i *deletableItem) Clone() any {
+	clone := *i
+	return &clone
+}
+
+func (i *deletableItem) AuditTrail() bool {
+	return true
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	setupTestDB(t, &deletableItem{}, &AuditTrail{})
+	viper.Set(global.DbAuditEnabled, true)
+	ctx := context.Background()
+
+	if err := Create(ctx, &deletableItem{Id: 1, Name: "one"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete(ctx, &deletableItem{Id: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if r, _ := GetById(ctx, &deletableItem{Id: 1}, nil); r.RowsAffected != 0 {
+		t.Fatalf("soft deleted item was read")
+	}
+	if r, _ := GetById(WithDeleted(ctx), &deletableItem{Id: 1}, nil); r.RowsAffected != 1 {
+		t.Fatalf("soft deleted item was not read with WithDeleted")
+	}
+
+	if r := Restore(ctx, &deletableItem{Id: 1}); r.Error != nil || r.RowsAffected != 1 {
+		t.Fatalf("restored %d items, expected 1. %v", r.RowsAffected, r.Error)
+	}
+	if r, _ := GetById(ctx, &deletableItem{Id: 1}, nil); r.RowsAffected != 1 {
+		t.Fatalf("restored item was not read")
+	}
+
+	var actions []string
+	if err := client.Model(&AuditTrail{}).Where("entity_table = ? AND entity_id = ?", "deletable_items", "1").
+		Order("id").Pluck("action", &actions).Error; err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{AuditActionCreate, AuditActionDelete, AuditActionRestore}
+	if len(actions) != len(expected) {
+		t.Fatalf("audit actions %v, expected %v", actions, expected)
+	}
+	for i := range expected {
+		if actions[i] != expected[i] {
+			t.Fatalf("audit actions %v, expected %v", actions, expected)
+		}
+	}
+}
+
+func TestRestoreRequiresPrimaryKey(t *testing.T) {
+	setupTestDB(t, &deletableItem{})
+	ctx := context.Background()
+
+	for _, id := range []int64{1, 2} {
+		if err := Create(ctx, &deletableItem{Id: id}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := Delete(ctx, &deletableItem{Id: id}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Restore(ctx, &deletableItem{}).Error; !errors.Is(err, ErrPrimaryKeyRequired) {
+		t.Fatalf("error %v, expected ErrPrimaryKeyRequired", err)
+	}
+	if r := RestoreWithBindHandlerHttp(ctx, &deletableItem{}); r.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status %d, expected 400", r.StatusCode)
+	}
+
+	var deleted int64
+	if err := client.Unscoped().Model(&deletableItem{}).Where("deleted_at IS NOT NULL").Count(&deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Fatalf("%d items still deleted, expected 2", deleted)
+	}
+}
+
+func TestListWithDeletedAppliesWhereOnce(t *testing.T) {
+	setupTestDB(t, &deletableItem{})
+	ctx := context.Background()
+
+	for _, item := range []*deletableItem{{Id: 1, Name: "n"}, {Id: 2, Name: "n"}, {Id: 3, Name: "m"}} {
+		if err := Create(ctx, item).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Delete(ctx, &deletableItem{Id: 2}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var statements []string
+	client.Callback().Query().After("gorm:query").Register("test:statements", func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement.SQL.String())
+	})
+	t.Cleanup(func() { _ = client.Callback().Query().Remove("test:statements") })
+
+	var items []deletableItem
+	page, _, err := List(WithDeleted(ctx), []string{"name = ?"}, "id", []string{"n"}, 10, 1, &deletableItem{}, items, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.TotalElements != 2 || page.NumberOfElements != 2 {
+		t.Fatalf("listed %d of %d items, expected 2 of 2", page.NumberOfElements, page.TotalElements)
+	}
+	for _, s := range statements {
+		if strings.Count(s, "name = ") != 1 {
+			t.Fatalf("statement %s must filter by name once", s)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/rlanhellas/aruna/logger"
+)
+
+var publishStatsOnce sync.Once
+
+// Stats return the connection pool statistics of the database client
+func Stats() (sql.DBStats, error) {
+	sqlDB, err := client.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
+
+// StartStatsReporter log pool statistics at each interval until ctx is done and publish them as the "db.pool" expvar metric
+func StartStatsReporter(ctx context.Context, interval time.Duration) {
+	publishStatsOnce.Do(func() {
+		expvar.Publish("db.pool", expvar.Func(func() any {
+			stats, err := Stats()
+			if err != nil {
+				return nil
+			}
+			return stats
+		}))
+	})
+
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := Stats()
+			if err != nil {
+				logger.Error(ctx, "error reading db pool stats. %s", err.Error())
+				continue
+			}
+			logger.Info(ctx, "db pool stats: open[%d], inUse[%d], idle[%d], waitCount[%d], waitDuration[%s], maxIdleClosed[%d], maxLifetimeClosed[%d]",
+				stats.OpenConnections, stats.InUse, stats.Idle, stats.WaitCount, stats.WaitDuration,
+				stats.MaxIdleClosed, stats.MaxLifetimeClosed)
+		}
+	}
+}
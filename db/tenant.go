@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sync"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/logger"
+	"github.com/rlanhellas/aruna/tenant"
+	"gorm.io/gorm"
+)
+
+// TenantOpener open a client whose connections are all bound to the tenant schema, used when tenants are isolated by schema
+type TenantOpener func(schema string) (*gorm.DB, error)
+
+var tenantOpener TenantOpener
+var tenantClients = map[string]*gorm.DB{}
+var tenantClientsMu sync.Mutex
+
+// SetTenantOpener configure how clients for tenant schemas are opened
+func SetTenantOpener(opener TenantOpener) {
+	tenantOpener = opener
+}
+
+// ProvisionTenant create the tenant schema when missing and run versioned migrations and auto migrate on it
+func ProvisionTenant(ctx context.Context, tenantID string, migrations fs.FS, migrateTables []any) error {
+	if !tenant.Allowed(tenantID) {
+		return tenant.ErrUnknownTenant
+	}
+
+	logger.Info(ctx, "provisioning tenant %s", tenantID)
+	if createSQL := dialect.CreateSchemaSQL(tenantID); createSQL != "" {
+		if err := client.WithContext(ctx).Exec(createSQL).Error; err != nil {
+			return fmt.Errorf("error creating schema for tenant %s. %w", tenantID, err)
+		}
+	}
+
+	tenantCtx := tenant.WithTenant(ctx, tenantID)
+	if migrations != nil {
+		if err := Migrate(tenantCtx, migrations); err != nil {
+			return err
+		}
+	}
+
+	for _, mt := range migrateTables {
+		logger.Debug(tenantCtx, "migrating table %s for tenant %s", reflect.TypeOf(mt).String(), tenantID)
+		if err := primary(tenantCtx).AutoMigrate(mt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// primary return the primary client, bound to the schema of the context tenant if any
+func primary(ctx context.Context) *gorm.DB {
+	tenantID := schemaTenant(ctx)
+	if tenantID == "" {
+		return client.WithContext(ctx)
+	}
+
+	c, err := tenantClient(tenantID)
+	if err != nil {
+		tx := client.WithContext(ctx)
+		tx.AddError(err)
+		return tx
+	}
+	return c.WithContext(ctx)
+}
+
+// schemaTenant return the context tenant when tenants are isolated by schema, empty otherwise
+func schemaTenant(ctx context.Context) string {
+	if tenantOpener == nil {
+		return ""
+	}
+	return tenant.FromContext(ctx)
+}
+
+// tenantContexts return one context per tenant schema, or ctx itself when tenants are not isolated by schema
+func tenantContexts(ctx context.Context) []context.Context {
+	if tenantOpener == nil {
+		return []context.Context{ctx}
+	}
+
+	ctxs := make([]context.Context, 0, len(config.TenantAllowed()))
+	for _, t := range config.TenantAllowed() {
+		ctxs = append(ctxs, tenant.WithTenant(ctx, t))
+	}
+	return ctxs
+}
+
+func tenantClient(tenantID string) (*gorm.DB, error) {
+	tenantClientsMu.Lock()
+	defer tenantClientsMu.Unlock()
+
+	if c, ok := tenantClients[tenantID]; ok {
+		return c, nil
+	}
+
+	if !tenant.Allowed(tenantID) {
+		return nil, tenant.ErrUnknownTenant
+	}
+
+	c, err := tenantOpener(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	registerCallbacks(c)
+	tenantClients[tenantID] = c
+	return c, nil
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/rlanhellas/aruna/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConflictError is returned when a versioned entity was modified since the version being updated was read
+type ConflictError struct {
+	Entity  string
+	Version int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("entity[%s] version %d was modified by another request", e.Entity, e.Version)
+}
+
+// StatusCode return 409, used by typed routes
+func (e *ConflictError) StatusCode() int {
+	return http.StatusConflict
+}
+
+// ErrorCode return the stable code of the error
+func (e *ConflictError) ErrorCode() string {
+	return "version_conflict"
+}
+
+// versionedSave save all fields of d, checking and incrementing the version when d is domain.Versioned
+func versionedSave(tx *gorm.DB, d domain.BaseDomain) *gorm.DB {
+	v, ok := d.(domain.Versioned)
+	if !ok {
+		return tx.Save(d)
+	}
+
+	current := v.GetVersion()
+	v.SetVersion(current + 1)
+	result := tx.Model(d).Select("*").Where(versionCondition(v, current)).Updates(d)
+	return checkVersion(result, d, v, current)
+}
+
+// versionedUpdates update attributes of d, checking and incrementing the version when d is domain.Versioned
+func versionedUpdates(tx *gorm.DB, d domain.BaseDomain, attributes map[string]interface{}) *gorm.DB {
+	v, ok := d.(domain.Versioned)
+	if !ok {
+		return tx.Model(d).Updates(attributes)
+	}
+
+	current := v.GetVersion()
+	attributes[v.VersionColumn()] = current + 1
+	result := tx.Model(d).Where(versionCondition(v, current)).Updates(attributes)
+	if result.Error == nil && result.RowsAffected > 0 {
+		v.SetVersion(current + 1)
+	}
+	return checkVersion(result, d, v, current)
+}
+
+func versionCondition(v domain.Versioned, version int64) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: v.VersionColumn()}, Value: version}
+}
+
+func checkVersion(result *gorm.DB, d domain.BaseDomain, v domain.Versioned, expected int64) *gorm.DB {
+	if result.Error == nil && result.RowsAffected == 0 {
+		v.SetVersion(expected)
+		result.AddError(&ConflictError{Entity: d.TableName(), Version: expected})
+	}
+	return result
+}
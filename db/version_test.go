@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rlanhellas/aruna/domain"
+)
+
+type versionedItem struct {
+	Id   int64 `gorm:"primaryKey"`
+	Name string
+	domain.VersionField
+}
+
+func (i *versionedItem) TableName() string {
+	return "versioned_items"
+}
+
+func (i *versionedItem) Clone() any {
+	clone := *i
+	return &clone
+}
+
+func TestUpdateRejectsStaleVersion(t *testing.T) {
+	setupTestDB(t, &versionedItem{})
+	ctx := context.Background()
+
+	if err := Create(ctx, &versionedItem{Id: 1, Name: "one"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	first := &versionedItem{Id: 1, Name: "first"}
+	second := &versionedItem{Id: 1, Name: "second"}
+	if err := Update(ctx, first).Error; err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != 1 {
+		t.Fatalf("version %d, expected 1", first.Version)
+	}
+
+	result := Update(ctx, second)
+	var conflict *ConflictError
+	if !errors.As(result.Error, &conflict) || conflict.Version != 0 {
+		t.Fatalf("error %v, expected conflict on version 0", result.Error)
+	}
+	if second.Version != 0 {
+		t.Fatalf("version %d kept after conflict, expected 0", second.Version)
+	}
+
+	stored := &versionedItem{Id: 1}
+	if err := client.First(stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "first" || stored.Version != 1 {
+		t.Fatalf("stored name %s, version %d, expected first and 1", stored.Name, stored.Version)
+	}
+}
+
+func TestUpdateSpecificAttributesChecksVersion(t *testing.T) {
+	setupTestDB(t, &versionedItem{})
+	ctx := context.Background()
+
+	if err := Create(ctx, &versionedItem{Id: 1, Name: "one"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	item := &versionedItem{Id: 1}
+	if err := UpdateSpecificAttributes(ctx, item, map[string]interface{}{"name": "two"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if item.Version != 1 {
+		t.Fatalf("version %d, expected 1", item.Version)
+	}
+
+	stale := &versionedItem{Id: 1}
+	var conflict *ConflictError
+	if err := UpdateSpecificAttributes(ctx, stale, map[string]interface{}{"name": "three"}).Error; !errors.As(err, &conflict) {
+		t.Fatalf("error %v, expected ConflictError", err)
+	}
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// CreationAudited domain whose creation fields are filled by db.Create
+type CreationAudited interface {
+	SetCreated(at time.Time, by string)
+}
+
+// ModificationAudited domain whose modification fields are filled by db.Create, db.Update and db.UpdateSpecificAttributes
+type ModificationAudited interface {
+	SetUpdated(at time.Time, by string)
+}
+
+// AuditTrailed domain whose creates, updates, deletes and restores are recorded in the audit trail table while
+// AuditTrail returns true, bulk operations included
+type AuditTrailed interface {
+	AuditTrail() bool
+}
+
+// AuditFields can be embedded in domains to implement CreationAudited and ModificationAudited
+type AuditFields struct {
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy"`
+}
+
+// SetCreated fill creation fields
+func (a *AuditFields) SetCreated(at time.Time, by string) {
+	a.CreatedAt = at
+	a.CreatedBy = by
+}
+
+// SetUpdated fill modification fields
+func (a *AuditFields) SetUpdated(at time.Time, by string) {
+	a.UpdatedAt = at
+	a.UpdatedBy = by
+}
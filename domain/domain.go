@@ -4,3 +4,35 @@ type BaseDomain interface {
 	TableName() string
 	Clone() any
 }
+
+// TenantScoped domain whose table is shared by tenants, rows are filled and filtered by the tenant column
+type TenantScoped interface {
+	TenantColumn() string
+}
+
+// Versioned domain protected by optimistic locking, the version is checked and incremented on each update
+type Versioned interface {
+	VersionColumn() string
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
+// VersionField can be embedded in domains to implement Versioned using the version column
+type VersionField struct {
+	Version int64 `json:"version"`
+}
+
+// VersionColumn return the version column
+func (v *VersionField) VersionColumn() string {
+	return "version"
+}
+
+// GetVersion return the current version
+func (v *VersionField) GetVersion() int64 {
+	return v.Version
+}
+
+// SetVersion set the current version
+func (v *VersionField) SetVersion(version int64) {
+	v.Version = version
+}
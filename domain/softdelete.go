@@ -0,0 +1,9 @@
+package domain
+
+import "gorm.io/gorm"
+
+// SoftDeleteField can be embedded in domains to opt-in soft delete, deleted rows are kept with deleted_at filled
+// and are excluded from reads unless db.WithDeleted is used
+type SoftDeleteField struct {
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+}
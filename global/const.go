@@ -3,23 +3,82 @@ package global
 const (
 	AppName              = "app.name"
 	AppVer               = "app.version"
+	AppProfile           = "app.profile"
 	LoggerLevel          = "logger.level"
 	LoggerPath           = "logger.path"
 	LoggerEncoding       = "logger.encoding"
 	HttpServerPort       = "http.port"
 	HttpServerEnabled    = "http.enabled"
+	HttpOpenAPIExport    = "http.openapi.export"
+	HttpProblemDetails   = "http.problemdetails.enabled"
+	HttpProblemTypeBase  = "http.problemdetails.typebase"
+	HttpShutdownTimeout  = "http.shutdowntimeout"
+	HttpDebugVars        = "http.debugvars.enabled"
+	HttpStreamHeartbeat  = "http.stream.heartbeat"
+	HttpStreamBuffer     = "http.stream.buffer"
+	HttpStreamSendWait   = "http.stream.sendtimeout"
+	HttpStreamReadMax    = "http.stream.readlimit"
+	HttpStreamOrigins    = "http.stream.allowedorigins"
+	HttpUploadMaxBody    = "http.upload.maxbodysize"
+	HttpUploadMaxFile    = "http.upload.maxfilesize"
+	HttpUploadTypes      = "http.upload.allowedtypes"
+	StorageLocalRoot     = "storage.local.root"
 	DbEnabled            = "db.enabled"
 	DbType               = "db.type"
 	DbConnectionString   = "db.connectionstring"
 	DbSchema             = "db.schema"
 	DbShowSQL            = "db.showsql"
+	DbPoolMaxOpen        = "db.pool.maxopen"
+	DbPoolMaxIdle        = "db.pool.maxidle"
+	DbPoolMaxLifetime    = "db.pool.maxlifetime"
+	DbPoolMaxIdleTime    = "db.pool.maxidletime"
+	DbConnectRetries     = "db.connect.retries"
+	DbConnectBackoff     = "db.connect.backoff"
+	DbStatsInterval      = "db.stats.interval"
+	DbMigrateOnly        = "db.migrate.only"
+	DbReplicas           = "db.replica.connectionstrings"
+	DbReplicaHealthCheck = "db.replica.healthinterval"
+	DbAuditEnabled       = "db.audit.enabled"
+	DbBatchSize          = "db.batchsize"
+	DbSoftDeleteRetain   = "db.softdelete.retention"
+	DbSoftDeletePurge    = "db.softdelete.purgeinterval"
+	DbTxMaxAttempts      = "db.transaction.maxattempts"
+	DbTxBackoff          = "db.transaction.backoff"
 	SecurityEnabled      = "security.enabled"
 	SecurityClientId     = "security.clientid"
 	SecurityClientSecret = "security.clientsecret"
 	SecurityTokenUri     = "security.tokenuri"
 	SecurityJwkUri       = "security.jwkuri"
+	SecurityPrincipal    = "security.principalclaim"
+	OutboxInterval       = "outbox.interval"
+	OutboxBatchSize      = "outbox.batchsize"
+	OutboxDispatchTime   = "outbox.dispatchtimeout"
+	OutboxMaxAttempts    = "outbox.maxattempts"
+	OutboxBackoff        = "outbox.backoff"
+	OutboxRetention      = "outbox.retention"
+	OutboxBlockOnFailure = "outbox.blockonfailure"
+	TenantEnabled        = "tenant.enabled"
+	TenantMode           = "tenant.mode"
+	TenantResolver       = "tenant.resolver"
+	TenantHeader         = "tenant.header"
+	TenantClaim          = "tenant.claim"
+	TenantAllowed        = "tenant.allowed"
 	CorrelationID        = "correlationid"
 	RequestForm          = "requestForm"
+	DbTransaction        = "dbtransaction"
+	DbForcePrimary       = "dbforceprimary"
+	DbIncludeDeleted     = "dbincludedeleted"
+	TenantID             = "tenantid"
+	TenantBypass         = "tenantbypass"
+	Principal            = "principal"
+	Claims               = "claims"
+	PathParams           = "pathparams"
 
-	PostgresDBType = "postgres"
+	PostgresDBType  = "postgres"
+	MySQLDBType     = "mysql"
+	SQLServerDBType = "sqlserver"
+	SQLiteDBType    = "sqlite"
+
+	TenantModeSchema = "schema"
+	TenantModeRow    = "row"
 )
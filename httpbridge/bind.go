@@ -0,0 +1,231 @@
+package httpbridge
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/rlanhellas/aruna/config"
+)
+
+// Tags read by bindInput, each one binds the field from a different part of the request
+const (
+	PathTag   = "path"
+	QueryTag  = "query"
+	HeaderTag = "header"
+	FormTag   = "form"
+	FileTag   = "file"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// errMalformedBody is returned by bindInput when the body can not be decoded
+type errMalformedBody struct {
+	err error
+}
+
+func (e *errMalformedBody) Error() string {
+	return fmt.Sprintf("can not bind your object. %s", e.err.Error())
+}
+
+func (e *errMalformedBody) ErrorCode() string {
+	return CodeMalformedRequest
+}
+
+func (e *errMalformedBody) Unwrap() error {
+	return e.err
+}
+
+// bindInput fill in with the request body decoded by the codec of its Content-Type, when present, and the fields
+// tagged with path, query, header, form and file, then validate it using binding struct tags. Tagged fields take
+// precedence over the body, and a field with many tags is read from path, query, header and form in this order
+func bindInput(ginctx *gin.Context, in any) error {
+	req := ginctx.Request
+	isForm := strings.HasPrefix(ginctx.ContentType(), binding.MIMEPOSTForm) ||
+		strings.HasPrefix(ginctx.ContentType(), binding.MIMEMultipartPOSTForm)
+
+	if isForm {
+		req.Body = http.MaxBytesReader(ginctx.Writer, req.Body, config.HttpUploadMaxBodySize())
+		if err := req.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return NewError(http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+					fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit))
+			}
+			return &errMalformedBody{err: err}
+		}
+	} else if err := bindBody(req, in); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		var e *Error
+		if errors.As(err, &typeErr) || errors.As(err, &e) {
+			return err
+		}
+		return &errMalformedBody{err: err}
+	}
+
+	rv := reflect.ValueOf(in)
+	if rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct {
+		var fields []FieldError
+		sources := []bindSource{
+			{tag: PathTag, lookup: func(name string) ([]string, bool) {
+				v, ok := ginctx.Params.Get(name)
+				return []string{v}, ok
+			}},
+			{tag: QueryTag, lookup: func(name string) ([]string, bool) {
+				v, ok := req.URL.Query()[name]
+				return v, ok
+			}},
+			{tag: HeaderTag, lookup: func(name string) ([]string, bool) {
+				v := req.Header.Values(name)
+				return v, len(v) > 0
+			}},
+			{tag: FormTag, lookup: func(name string) ([]string, bool) {
+				v, ok := req.PostForm[name]
+				return v, ok && isForm
+			}},
+		}
+		bindTagged(rv.Elem(), sources, &fields)
+		if len(fields) > 0 {
+			return &ValidationError{Fields: fields}
+		}
+		if err := bindFiles(rv.Elem(), req.MultipartForm); err != nil {
+			return err
+		}
+	}
+
+	return binding.Validator.ValidateStruct(in)
+}
+
+// bindBody decode the body into in with the codec of its Content-Type, requests without body like most GET and DELETE
+// are ignored
+func bindBody(req *http.Request, in any) error {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil
+	}
+
+	c, ok := codecFor(req.Header.Get("Content-Type"))
+	if !ok {
+		return NewError(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+			fmt.Sprintf("content type %s is not supported", req.Header.Get("Content-Type")))
+	}
+	if err := c.Decode(req.Body, in); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// bindSource request values read for fields tagged with tag
+type bindSource struct {
+	tag    string
+	lookup func(name string) ([]string, bool)
+}
+
+// bindTagged set the fields of the struct v tagged by one of sources, embedded structs included. A field tagged for
+// many sources is set by the first one, in sources order, holding a value
+func bindTagged(v reflect.Value, sources []bindSource, fields *[]FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			bindTagged(v.Field(i), sources, fields)
+			continue
+		}
+
+		for _, source := range sources {
+			name := strings.SplitN(sf.Tag.Get(source.tag), ",", 2)[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			values, ok := source.lookup(name)
+			if !ok || len(values) == 0 {
+				continue
+			}
+			if err := setValue(v.Field(i), values); err != nil {
+				*fields = append(*fields, FieldError{
+					Field:   name,
+					Code:    "type",
+					Message: fmt.Sprintf("must be of type %s", sf.Type.String()),
+				})
+			}
+			break
+		}
+	}
+}
+
+// setValue convert values to the type of field and set it, slices receive all values, other types the first one
+func setValue(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		v := reflect.New(field.Type().Elem())
+		if err := setValue(v.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(v)
+		return nil
+	}
+
+	if reflect.PtrTo(field.Type()).Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(values[0]))
+	}
+
+	switch field.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), []string{value}); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	case reflect.String:
+		field.SetString(values[0])
+	case reflect.Bool:
+		b, err := strconv.ParseBool(values[0])
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(values[0])
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(values[0], 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(values[0], 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(values[0], field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package httpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type boundInput struct {
+	Id      int64    `path:"id"`
+	Name    string   `path:"name" query:"name" header:"X-Name" form:"name"`
+	Tags    []string `query:"tag"`
+	Limit   int      `query:"limit" form:"limit"`
+	Comment string   `json:"comment"`
+}
+
+func bindRoute(path string, bound *boundInput) *RouteHttp {
+	return &RouteHttp{
+		Method:                http.MethodPost,
+		Path:                  path,
+		HandlerInputGenerator: func() any { return &boundInput{} },
+		Handler: func(ctx context.Context, in any, ginctx *gin.Context) *HandlerHttpResponse {
+			*bound = *in.(*boundInput)
+			return NewHandlerHttpResponse(nil, http.StatusNoContent, nil)
+		},
+	}
+}
+
+func TestBindInputReadsSourcesInFixedOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		target   string
+		header   string
+		form     url.Values
+		expected string
+	}{
+		{"path first", "/items/:id/:name", "/items/1/p?name=q", "h", url.Values{"name": {"f"}}, "p"},
+		{"query before header", "/items/:id", "/items/1?name=q", "h", url.Values{"name": {"f"}}, "q"},
+		{"header before form", "/items/:id", "/items/1", "h", url.Values{"name": {"f"}}, "h"},
+		{"form last", "/items/:id", "/items/1", "", url.Values{"name": {"f"}}, "f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// run several times, sources used to be read in map order
+			for i := 0; i < 20; i++ {
+				req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				if tt.header != "" {
+					req.Header.Set("X-Name", tt.header)
+				}
+
+				var bound boundInput
+				w := serveRoute(t, bindRoute(tt.path, &bound), req)
+				if w.Code != http.StatusNoContent {
+					t.Fatalf("status %d, expected 204. %s", w.Code, w.Body.String())
+				}
+				if bound.Name != tt.expected {
+					t.Fatalf("name %q, expected %q", bound.Name, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestBindInputMergesBodyAndTags(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/items/7?tag=a&tag=b&limit=5", strings.NewReader(`{"comment":"hi","Limit":1}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var bound boundInput
+	w := serveRoute(t, bindRoute("/items/:id", &bound), req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status %d, expected 204. %s", w.Code, w.Body.String())
+	}
+	if bound.Id != 7 || bound.Comment != "hi" || bound.Limit != 5 || strings.Join(bound.Tags, ",") != "a,b" {
+		t.Fatalf("bound %+v", bound)
+	}
+}
+
+func TestBindInputRejectsInvalidTypes(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/items/abc?limit=many", nil)
+
+	var bound boundInput
+	w := serveRoute(t, bindRoute("/items/:id", &bound), req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, expected 400. %s", w.Code, w.Body.String())
+	}
+
+	var body BaseHttpResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.FieldErrors) != 2 {
+		t.Fatalf("body %+v, expected two field errors", body)
+	}
+	for i, field := range []string{"id", "limit"} {
+		if body.FieldErrors[i].Field != field || body.FieldErrors[i].Code != "type" {
+			t.Fatalf("field error %+v, expected type error of %s", body.FieldErrors[i], field)
+		}
+	}
+}
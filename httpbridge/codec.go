@@ -0,0 +1,442 @@
+package httpbridge
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrProtoMessageRequired is returned by the protobuf codec for values which are not proto.Message
+var ErrProtoMessageRequired = errors.New("protobuf codec requires a proto.Message")
+
+// ErrCSVRowsRequired is returned by the csv codec for values which are not a struct or a slice of structs
+var ErrCSVRowsRequired = errors.New("csv codec requires a struct or a slice of structs")
+
+// Codec decode request bodies and encode responses of its content types
+type Codec interface {
+	// ContentTypes media types handled by the codec, the first one is sent in responses
+	ContentTypes() []string
+	Decode(r io.Reader, v any) error
+	Encode(w io.Writer, v any) error
+}
+
+var codecs = map[string]Codec{}
+var codecsMu sync.RWMutex
+var defaultCodec Codec = &jsonCodec{}
+
+func init() {
+	RegisterCodec(defaultCodec)
+	RegisterCodec(&xmlCodec{})
+	RegisterCodec(&msgpackCodec{})
+	RegisterCodec(&protobufCodec{})
+	RegisterCodec(&csvCodec{})
+}
+
+// RegisterCodec register a codec for its content types, overriding any codec registered for them
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	for _, ct := range c.ContentTypes() {
+		codecs[ct] = c
+	}
+}
+
+// codecFor return the codec of the request Content-Type, json when it is missing
+func codecFor(contentType string) (Codec, bool) {
+	if contentType == "" {
+		return defaultCodec, true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[mediaType]
+	return c, ok
+}
+
+// negotiate return the codec of the media type preferred by Accept, json when Accept is missing. ok is false when
+// Accept only lists media types without a registered codec
+func negotiate(accept string) (c Codec, ok bool) {
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []mediaRange
+	parsed := false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		parsed = true
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+		}
+	}
+	// a missing or unparseable Accept means the client accepts anything
+	if !parsed {
+		return defaultCodec, true
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, r := range ranges {
+		if r.mediaType == "*/*" || r.mediaType == "application/*" {
+			return defaultCodec, true
+		}
+		if c, ok := codecs[r.mediaType]; ok {
+			return c, true
+		}
+		if strings.HasSuffix(r.mediaType, "/*") {
+			if c, ok := codecWithPrefix(strings.TrimSuffix(r.mediaType, "*")); ok {
+				return c, true
+			}
+		}
+	}
+	return defaultCodec, false
+}
+
+// codecWithPrefix return the codec of the first media type, in alphabetical order, starting with prefix. codecsMu
+// must be held by the caller
+func codecWithPrefix(prefix string) (Codec, bool) {
+	mediaTypes := make([]string, 0, len(codecs))
+	for mediaType := range codecs {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	for _, mediaType := range mediaTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return codecs[mediaType], true
+		}
+	}
+	return nil, false
+}
+
+// acceptable return true when the Accept header of the request matches a registered codec
+func acceptable(ginctx *gin.Context) bool {
+	_, ok := negotiate(ginctx.GetHeader("Accept"))
+	return ok
+}
+
+// render encode v with the codec negotiated by the Accept header, falling back to json when none is acceptable or it
+// can not encode v
+func render(ginctx *gin.Context, statusCode int, v any) {
+	c, _ := negotiate(ginctx.GetHeader("Accept"))
+	if c != defaultCodec {
+		var buf bytes.Buffer
+		if err := c.Encode(&buf, v); err == nil {
+			ginctx.Data(statusCode, c.ContentTypes()[0], buf.Bytes())
+			return
+		}
+	}
+	ginctx.JSON(statusCode, v)
+}
+
+type jsonCodec struct{}
+
+func (c *jsonCodec) ContentTypes() []string {
+	return []string{binding.MIMEJSON}
+}
+
+func (c *jsonCodec) Decode(r io.Reader, v any) error {
+	decoder := json.NewDecoder(r)
+	if binding.EnableDecoderUseNumber {
+		decoder.UseNumber()
+	}
+	if binding.EnableDecoderDisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(v)
+}
+
+func (c *jsonCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type xmlCodec struct{}
+
+func (c *xmlCodec) ContentTypes() []string {
+	return []string{binding.MIMEXML, binding.MIMEXML2}
+}
+
+func (c *xmlCodec) Decode(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+func (c *xmlCodec) Encode(w io.Writer, v any) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+type msgpackCodec struct{}
+
+func (c *msgpackCodec) ContentTypes() []string {
+	return []string{binding.MIMEMSGPACK2, binding.MIMEMSGPACK}
+}
+
+func (c *msgpackCodec) Decode(r io.Reader, v any) error {
+	return codec.NewDecoder(r, new(codec.MsgpackHandle)).Decode(v)
+}
+
+func (c *msgpackCodec) Encode(w io.Writer, v any) error {
+	return codec.NewEncoder(w, new(codec.MsgpackHandle)).Encode(v)
+}
+
+// protobufCodec decode inputs and encode response data implementing proto.Message, the BaseHttpResponse envelope is
+// not sent since it has no protobuf definition
+type protobufCodec struct{}
+
+func (c *protobufCodec) ContentTypes() []string {
+	return []string{binding.MIMEPROTOBUF, "application/protobuf"}
+}
+
+func (c *protobufCodec) Decode(r io.Reader, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrProtoMessageRequired
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+func (c *protobufCodec) Encode(w io.Writer, v any) error {
+	if response, ok := v.(BaseHttpResponse); ok {
+		if response.ErrorMessage != "" {
+			return ErrProtoMessageRequired
+		}
+		v = response.Data
+	}
+	if p, ok := v.(*any); ok && p != nil {
+		v = *p
+	}
+
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrProtoMessageRequired
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// csvCodec encode response data holding a struct or a slice of structs as a header line followed by one record per
+// struct, and decode such bodies into a struct or a slice of structs. Columns are named by the json tag, fields of
+// embedded structs included, and cells of nested structs, maps and slices hold their json. The BaseHttpResponse
+// envelope is not sent, like for protobuf
+type csvCodec struct{}
+
+func (c *csvCodec) ContentTypes() []string {
+	return []string{"text/csv"}
+}
+
+func (c *csvCodec) Decode(r io.Reader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrCSVRowsRequired
+	}
+	rv = rv.Elem()
+	rowType := rv.Type()
+	if rv.Kind() == reflect.Slice {
+		rowType = rowType.Elem()
+	}
+	if indirectType(rowType).Kind() != reflect.Struct {
+		return ErrCSVRowsRequired
+	}
+
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	columns := csvColumns(indirectType(rowType))
+	byName := make(map[string]csvColumn, len(columns))
+	for _, column := range columns {
+		byName[column.name] = column
+	}
+
+	rows := reflect.MakeSlice(reflect.SliceOf(rowType), 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := reflect.New(indirectType(rowType))
+		for i, cell := range record {
+			column, ok := byName[records[0][i]]
+			if !ok || cell == "" {
+				continue
+			}
+			if err := setCSVCell(row.Elem().FieldByIndex(column.index), cell); err != nil {
+				return fmt.Errorf("column %s. %w", column.name, err)
+			}
+		}
+		if rowType.Kind() != reflect.Ptr {
+			row = row.Elem()
+		}
+		rows = reflect.Append(rows, row)
+	}
+
+	if rv.Kind() == reflect.Slice {
+		rv.Set(rows)
+	} else if rows.Len() > 0 {
+		rv.Set(rows.Index(0))
+	}
+	return nil
+}
+
+func (c *csvCodec) Encode(w io.Writer, v any) error {
+	if response, ok := v.(BaseHttpResponse); ok {
+		if response.ErrorMessage != "" {
+			return ErrCSVRowsRequired
+		}
+		v = response.Data
+	}
+	if p, ok := v.(*any); ok && p != nil {
+		v = *p
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct {
+		single := reflect.New(reflect.SliceOf(rv.Type())).Elem()
+		rv = reflect.Append(single, rv)
+	}
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || indirectType(rv.Type().Elem()).Kind() != reflect.Struct {
+		return ErrCSVRowsRequired
+	}
+
+	columns := csvColumns(indirectType(rv.Type().Elem()))
+	writer := csv.NewWriter(w)
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = column.name
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		row := rv.Index(i)
+		if row.Kind() == reflect.Ptr {
+			if row.IsNil() {
+				continue
+			}
+			row = row.Elem()
+		}
+		for j, column := range columns {
+			cell, err := csvCell(row.FieldByIndex(column.index))
+			if err != nil {
+				return fmt.Errorf("column %s. %w", column.name, err)
+			}
+			record[j] = cell
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvColumn exported field of a struct sent as a csv column
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+// csvColumns return the columns of the struct type t in field order, fields of embedded structs included
+func csvColumns(t reflect.Type) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.SplitN(sf.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+
+		// exported fields of embedded structs are promoted even when the embedded type is not exported, like in json
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && name == "" {
+			for _, column := range csvColumns(sf.Type) {
+				column.index = append([]int{i}, column.index...)
+				columns = append(columns, column)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		columns = append(columns, csvColumn{name: name, index: []int{i}})
+	}
+	return columns
+}
+
+// csvCell format the field value as a csv cell, nil pointers are empty
+func csvCell(field reflect.Value) (string, error) {
+	for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return "", nil
+		}
+		field = field.Elem()
+	}
+
+	if m, ok := field.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	switch field.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		b, err := json.Marshal(field.Interface())
+		return string(b), err
+	default:
+		return fmt.Sprint(field.Interface()), nil
+	}
+}
+
+// setCSVCell parse the csv cell into field, nested structs, maps and slices are read as json
+func setCSVCell(field reflect.Value, cell string) error {
+	t := indirectType(field.Type())
+	if !reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		switch t.Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+			return json.Unmarshal([]byte(cell), field.Addr().Interface())
+		}
+	}
+	return setValue(field, []string{cell})
+}
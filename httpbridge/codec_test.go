@@ -0,0 +1,151 @@
+package httpbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept     string
+		expected   string
+		acceptable bool
+	}{
+		{"", "application/json", true},
+		{"*/*", "application/json", true},
+		{"application/xml", "application/xml", true},
+		{"text/csv;q=0.5, application/msgpack", "application/msgpack", true},
+		{"text/*", "text/csv", true},
+		{"image/png, application/*;q=0.1", "application/json", true},
+		{"image/png", "", false},
+		{"application/json;q=0", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			c, ok := negotiate(tt.accept)
+			if ok != tt.acceptable {
+				t.Fatalf("acceptable %t, expected %t", ok, tt.acceptable)
+			}
+			if ok && c.ContentTypes()[0] != tt.expected {
+				t.Fatalf("codec %s, expected %s", c.ContentTypes()[0], tt.expected)
+			}
+		})
+	}
+}
+
+type reportOwner struct {
+	Name string `json:"name"`
+}
+
+type reportAudit struct {
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type reportRow struct {
+	Id     int64       `json:"id"`
+	Title  string      `json:"title"`
+	Score  *float64    `json:"score"`
+	Owner  reportOwner `json:"owner"`
+	Secret string      `json:"-"`
+	reportAudit
+}
+
+func reportRoute(rows []reportRow) *RouteHttp {
+	return Route(http.MethodGet, "/reports", func(ctx context.Context, in *struct{}) ([]reportRow, error) {
+		return rows, nil
+	})
+}
+
+func TestCSVCodecEncodesAndDecodesRows(t *testing.T) {
+	score := 9.5
+	createdAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := []reportRow{
+		{Id: 1, Title: "first, quoted", Score: &score, Owner: reportOwner{Name: "ana"}, Secret: "s", reportAudit: reportAudit{CreatedAt: createdAt}},
+		{Id: 2, Title: "second", reportAudit: reportAudit{CreatedAt: createdAt}},
+	}
+
+	var buf bytes.Buffer
+	if err := (&csvCodec{}).Encode(&buf, BaseHttpResponse{Data: rows}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "id,title,score,owner,createdAt\n" +
+		"1,\"first, quoted\",9.5,\"{\"\"name\"\":\"\"ana\"\"}\",2023-01-02T03:04:05Z\n" +
+		"2,second,,\"{\"\"name\"\":\"\"\"\"}\",2023-01-02T03:04:05Z\n"
+	if buf.String() != expected {
+		t.Fatalf("csv\n%s\nexpected\n%s", buf.String(), expected)
+	}
+
+	var decoded []reportRow
+	if err := (&csvCodec{}).Decode(&buf, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	rows[0].Secret = ""
+	if !reflect.DeepEqual(decoded, rows) {
+		t.Fatalf("decoded %+v, expected %+v", decoded, rows)
+	}
+}
+
+func TestCSVCodecRejectsErrorsAndScalars(t *testing.T) {
+	for _, v := range []any{BaseHttpResponse{ErrorMessage: "boom"}, BaseHttpResponse{Data: 1}, []string{"a"}} {
+		if err := (&csvCodec{}).Encode(&bytes.Buffer{}, v); err != ErrCSVRowsRequired {
+			t.Fatalf("encoding %+v returned %v, expected ErrCSVRowsRequired", v, err)
+		}
+	}
+}
+
+func TestRouteRendersNegotiatedContentType(t *testing.T) {
+	rows := []reportRow{{Id: 1, Title: "first"}}
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"text/csv", http.StatusOK, "text/csv"},
+		{"application/xml;q=0.5, application/msgpack", http.StatusOK, "application/msgpack"},
+		{"", http.StatusOK, "application/json; charset=utf-8"},
+		{"image/png", http.StatusNotAcceptable, "application/json; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := serveRoute(t, reportRoute(rows), req)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, expected %d. %s", w.Code, tt.status, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Fatalf("content type %s, expected %s", ct, tt.contentType)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	req.Header.Set("Accept", "image/png")
+	var body BaseHttpResponse
+	if err := json.Unmarshal(serveRoute(t, reportRoute(rows), req).Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.ErrorCode != CodeNotAcceptable || body.Data != nil {
+		t.Fatalf("body %+v, expected not acceptable error without data", body)
+	}
+}
+
+func TestBindInputRejectsUnsupportedContentType(t *testing.T) {
+	route := Route(http.MethodPost, "/items", func(ctx context.Context, in *createItem) (*createdItem, error) {
+		return &createdItem{Id: 1, Name: in.Name}, nil
+	})
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader("name: one"))
+	req.Header.Set("Content-Type", "application/x-yaml")
+
+	w := serveRoute(t, route, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status %d, expected 415. %s", w.Code, w.Body.String())
+	}
+}
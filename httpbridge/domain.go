@@ -2,13 +2,18 @@ package httpbridge
 
 import (
 	"context"
+	"encoding/xml"
 	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
 )
 
 type RouteGroupHttp struct {
-	Path          string
-	Authenticated bool
-	Routes        []*RouteHttp
+	Path            string
+	Authenticated   bool
+	Routes          []*RouteHttp
+	SSERoutes       []*RouteSSE
+	WebSocketRoutes []*RouteWebSocket
 }
 
 // RouteHttp configure a http route
@@ -17,19 +22,42 @@ type RouteHttp struct {
 	Handler               func(ctx context.Context, in any, ginctx *gin.Context) *HandlerHttpResponse
 	HandlerInputGenerator func() any //func to generate new input instance to be bound in HTTP request
 	Method                string
+	// optional, used only to describe the route in the OpenAPI document
+	HandlerOutputGenerator func() any //func to generate an instance of the response data
+	Summary                string
+	Description            string
 }
 
 // BaseHttpResponse json which will be rendered in response for the user
 type BaseHttpResponse struct {
-	ErrorMessage string `json:"error_message"`
-	Data         any    `json:"data"`
+	XMLName      xml.Name     `json:"-" xml:"response" codec:"-"`
+	ErrorMessage string       `json:"error_message" xml:"error_message"`
+	ErrorCode    string       `json:"error_code,omitempty" xml:"error_code,omitempty"`
+	FieldErrors  []FieldError `json:"field_errors,omitempty" xml:"field_error,omitempty"`
+	Data         any          `json:"data" xml:"data"`
 }
 
-// HandlerHttpResponse handler response to be transformed in BaseHttpResponse later on
+// HandlerHttpResponse handler response to be transformed in BaseHttpResponse later on, unless it carries a raw body,
+// a stream or a redirect, which are sent without wrapping
 type HandlerHttpResponse struct {
 	Error      error
 	Data       any
 	StatusCode int
+	Headers    http.Header //optional, added to the response whatever its kind
+
+	ContentType   string    //content type of Body or Stream, application/octet-stream when empty
+	Body          []byte    //raw body sent as is
+	Stream        io.Reader //body copied to the response, closed at the end when it is an io.Closer
+	ContentLength int64     //length of Stream, zero when unknown
+	Location      string    //redirect location with 3xx or 201, StatusCode defaults to 302. Only a header otherwise
+}
+
+// SanitizedError error translated to a status code and a message safe to be rendered to clients, the original error
+// is only logged
+type SanitizedError interface {
+	error
+	StatusCode() int
+	SanitizedMessage() string
 }
 
 // NewHandlerHttpResponse is new
@@ -40,3 +68,37 @@ func NewHandlerHttpResponse(err error, statusCode int, data any) *HandlerHttpRes
 		Data:       &data,
 	}
 }
+
+// NewRawResponse create a response sending body as is
+func NewRawResponse(statusCode int, contentType string, body []byte) *HandlerHttpResponse {
+	return &HandlerHttpResponse{
+		StatusCode:  statusCode,
+		ContentType: contentType,
+		Body:        body,
+	}
+}
+
+// NewStreamResponse create a response copying stream to the client, use contentLength zero when it is unknown
+func NewStreamResponse(statusCode int, contentType string, stream io.Reader, contentLength int64) *HandlerHttpResponse {
+	return &HandlerHttpResponse{
+		StatusCode:    statusCode,
+		ContentType:   contentType,
+		Stream:        stream,
+		ContentLength: contentLength,
+	}
+}
+
+// NewRedirectResponse create a response redirecting to location, statusCode must be 3xx or 201
+func NewRedirectResponse(statusCode int, location string) *HandlerHttpResponse {
+	return &HandlerHttpResponse{
+		StatusCode: statusCode,
+		Location:   location,
+	}
+}
+
+// NewNoContentResponse create a 204 response without body
+func NewNoContentResponse() *HandlerHttpResponse {
+	return &HandlerHttpResponse{
+		StatusCode: http.StatusNoContent,
+	}
+}
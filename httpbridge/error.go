@@ -0,0 +1,150 @@
+package httpbridge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/global"
+)
+
+// Stable codes of the errors raised by HttpHandler itself
+const (
+	CodeValidationFailed     = "validation_failed"
+	CodeMalformedRequest     = "malformed_request"
+	CodeTenantForbidden      = "tenant_forbidden"
+	CodeInvalidIfMatch       = "invalid_if_match"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeNotAcceptable        = "not_acceptable"
+	CodeShuttingDown         = "shutting_down"
+)
+
+const problemContentType = "application/problem+json"
+
+const genericErrorMessage = "internal server error"
+
+// ErrorCoder error carrying a stable code identifying it to clients
+type ErrorCoder interface {
+	ErrorCode() string
+}
+
+// Error typed error to be returned by handlers, Code is a stable identifier clients can rely on instead of the message
+type Error struct {
+	Status int
+	Code   string
+	Title  string // optional, defaults to the status text
+	Detail string
+	Fields []FieldError
+	Err    error // optional cause, only logged
+}
+
+// NewError create an error with status, stable code and a detail message shown to clients
+func NewError(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Detail + ". " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) StatusCode() int {
+	return e.Status
+}
+
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+// ProblemDetails RFC 7807 error body, rendered instead of BaseHttpResponse when http.problemdetails.enabled is set
+type ProblemDetails struct {
+	Type          string       `json:"type"`
+	Title         string       `json:"title"`
+	Status        int          `json:"status"`
+	Detail        string       `json:"detail,omitempty"`
+	Instance      string       `json:"instance,omitempty"`
+	Code          string       `json:"code,omitempty"`
+	FieldErrors   []FieldError `json:"field_errors,omitempty"`
+	CorrelationID string       `json:"correlation_id,omitempty"`
+}
+
+// toError turn err into *Error, using statusCode unless err carries its own status. Messages of 5xx errors which are
+// neither *Error nor SanitizedError are replaced by a generic one, since they may carry internal details
+func toError(err error, statusCode int) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Status < http.StatusBadRequest {
+			withStatus := *e
+			withStatus.Status = http.StatusInternalServerError
+			return &withStatus
+		}
+		return e
+	}
+
+	e = &Error{Status: statusCode, Detail: err.Error(), Err: err}
+	var sanitized SanitizedError
+	var statusCoder StatusCoder
+	if errors.As(err, &sanitized) {
+		e.Status = sanitized.StatusCode()
+		e.Detail = sanitized.SanitizedMessage()
+	} else {
+		if errors.As(err, &statusCoder) {
+			e.Status = statusCoder.StatusCode()
+		}
+		if e.Status >= http.StatusInternalServerError {
+			e.Detail = genericErrorMessage
+		}
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		e.Code = CodeValidationFailed
+		e.Fields = validationErr.Fields
+	}
+	var coder ErrorCoder
+	if errors.As(err, &coder) {
+		e.Code = coder.ErrorCode()
+	}
+	return e
+}
+
+// writeError render e as problem details when enabled, as BaseHttpResponse otherwise
+func writeError(ginctx *gin.Context, ctx context.Context, e *Error, data any) {
+	if !config.HttpProblemDetails() {
+		render(ginctx, e.Status, BaseHttpResponse{
+			ErrorMessage: e.Detail,
+			ErrorCode:    e.Code,
+			FieldErrors:  e.Fields,
+			Data:         data,
+		})
+		return
+	}
+
+	problem := ProblemDetails{
+		Type:        "about:blank",
+		Title:       e.Title,
+		Status:      e.Status,
+		Detail:      e.Detail,
+		Instance:    ginctx.Request.URL.Path,
+		Code:        e.Code,
+		FieldErrors: e.Fields,
+	}
+	if base := config.HttpProblemTypeBase(); base != "" && e.Code != "" {
+		problem.Type = base + e.Code
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(e.Status)
+	}
+	problem.CorrelationID, _ = ctx.Value(global.CorrelationID).(string)
+
+	ginctx.Header("Content-Type", problemContentType)
+	ginctx.JSON(e.Status, problem)
+}
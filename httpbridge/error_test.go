@@ -0,0 +1,133 @@
+package httpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rlanhellas/aruna/global"
+	"github.com/spf13/viper"
+)
+
+type sanitizedError struct{}
+
+func (e *sanitizedError) Error() string {
+	return "dial tcp 10.0.0.1:5432: connection refused"
+}
+
+func (e *sanitizedError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+func (e *sanitizedError) SanitizedMessage() string {
+	return "database unavailable"
+}
+
+func TestToError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"typed error", NewError(http.StatusForbidden, "forbidden", "not yours"), http.StatusForbidden, "forbidden", "not yours"},
+		{"typed error without status", &Error{Code: "broken", Detail: "broken"}, http.StatusInternalServerError, "broken", "broken"},
+		{"status coder", fmt.Errorf("saving. %w", &statusError{status: http.StatusConflict}), http.StatusConflict, "", "saving. status 409"},
+		{"sanitized error", &sanitizedError{}, http.StatusServiceUnavailable, "", "database unavailable"},
+		{"plain 5xx error", errors.New("pq: password authentication failed"), http.StatusInternalServerError, "", genericErrorMessage},
+		{"validation error", &ValidationError{Fields: []FieldError{{Field: "name", Message: "is required"}}}, http.StatusBadRequest, CodeValidationFailed, "invalid input: name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := http.StatusInternalServerError
+			if tt.status < http.StatusInternalServerError {
+				status = tt.status
+			}
+			e := toError(tt.err, status)
+			if e.Status != tt.status || e.Code != tt.code || e.Detail != tt.detail {
+				t.Fatalf("error %+v, expected status %d, code %q, detail %q", e, tt.status, tt.code, tt.detail)
+			}
+		})
+	}
+}
+
+func writeTestError(t *testing.T, e *Error) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	ginctx, _ := gin.CreateTestContext(w)
+	ginctx.Request = httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	writeError(ginctx, context.WithValue(context.Background(), global.CorrelationID, "c-1"), e, nil)
+	return w
+}
+
+func TestWriteErrorRendersProblemDetails(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set(global.HttpProblemDetails, true)
+	viper.Set(global.HttpProblemTypeBase, "https://errors.example.com/")
+
+	w := writeTestError(t, &Error{
+		Status: http.StatusUnprocessableEntity,
+		Code:   CodeValidationFailed,
+		Detail: "invalid input",
+		Fields: []FieldError{{Field: "name", Code: "required", Message: "is required"}},
+	})
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, expected 422", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+		t.Fatalf("content type %s, expected %s", ct, problemContentType)
+	}
+
+	var problem ProblemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	expected := ProblemDetails{
+		Type:          "https://errors.example.com/validation_failed",
+		Title:         "Unprocessable Entity",
+		Status:        http.StatusUnprocessableEntity,
+		Detail:        "invalid input",
+		Instance:      "/items/1",
+		Code:          CodeValidationFailed,
+		FieldErrors:   []FieldError{{Field: "name", Code: "required", Message: "is required"}},
+		CorrelationID: "c-1",
+	}
+	if fmt.Sprintf("%+v", problem) != fmt.Sprintf("%+v", expected) {
+		t.Fatalf("problem %+v, expected %+v", problem, expected)
+	}
+}
+
+func TestWriteErrorRendersBaseResponseByDefault(t *testing.T) {
+	w := writeTestError(t, NewError(http.StatusNotFound, "item_not_found", "item 1 not found"))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status %d, expected 404", w.Code)
+	}
+	var body BaseHttpResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.ErrorCode != "item_not_found" || body.ErrorMessage != "item 1 not found" {
+		t.Fatalf("body %+v", body)
+	}
+}
+
+func TestWriteErrorUsesAboutBlankWithoutTypeBase(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set(global.HttpProblemDetails, true)
+
+	var problem ProblemDetails
+	if err := json.Unmarshal(writeTestError(t, NewError(http.StatusConflict, "taken", "name taken")).Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Type != "about:blank" || problem.Title != "Conflict" {
+		t.Fatalf("problem %+v, expected about:blank type and Conflict title", problem)
+	}
+}
@@ -0,0 +1,45 @@
+package httpbridge
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rlanhellas/aruna/domain"
+)
+
+var ErrInvalidIfMatch = errors.New("invalid If-Match header, expected an ETag returned by a previous response")
+
+// ETag return the ETag header value of a version
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// IfMatchVersion return the version sent in If-Match header, present is false when header is missing or is *
+func IfMatchVersion(ginctx *gin.Context) (version int64, present bool, err error) {
+	ifMatch := strings.TrimSpace(ginctx.GetHeader("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, false, nil
+	}
+
+	tag, err := strconv.Unquote(strings.TrimPrefix(ifMatch, "W/"))
+	if err != nil {
+		return 0, false, ErrInvalidIfMatch
+	}
+
+	version, err = strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		return 0, false, ErrInvalidIfMatch
+	}
+	return version, true, nil
+}
+
+// versioned return data as domain.Versioned, looking through the pointer used by NewHandlerHttpResponse
+func versioned(data any) (domain.Versioned, bool) {
+	if p, ok := data.(*any); ok && p != nil {
+		data = *p
+	}
+	v, ok := data.(domain.Versioned)
+	return v, ok
+}
@@ -2,40 +2,187 @@ package httpbridge
 
 import (
 	"context"
-	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/domain"
 	"github.com/rlanhellas/aruna/global"
 	"github.com/rlanhellas/aruna/logger"
+	"github.com/rlanhellas/aruna/security"
+	"github.com/rlanhellas/aruna/tenant"
 )
 
 // HttpHandler handler for all http requests
 func HttpHandler(ginctx *gin.Context, ctx context.Context, routeHttp *RouteHttp) {
-	newCtx := context.WithValue(ctx, global.CorrelationID, ginctx.GetHeader(global.CorrelationID))
-
+	newCtx, ok := requestContext(ginctx, ctx)
+	if !ok {
+		return
+	}
 	logger.Debug(newCtx, "handling path %s, method %s", routeHttp.Path, routeHttp.Method)
+	// the request was replaced by requestContext, so the server does not remove the multipart temp files by itself
+	defer func() {
+		if ginctx.Request.MultipartForm != nil {
+			_ = ginctx.Request.MultipartForm.RemoveAll()
+		}
+	}()
 
 	var in any
 	if routeHttp.HandlerInputGenerator != nil {
 		in = routeHttp.HandlerInputGenerator()
-		err := ginctx.BindJSON(in)
+		validatorEngine()
+		err := bindInput(ginctx, in)
 		if err != nil {
-			logger.Error(newCtx, "error trying to bindJson. %s", err.Error())
-			ginctx.JSON(http.StatusBadRequest, BaseHttpResponse{
-				ErrorMessage: fmt.Sprintf("can not bind your object to json. %s", err.Error()),
-			})
+			logger.Error(newCtx, "error trying to bind input. %s", err.Error())
+			statusCode, validationErr := bindError(err)
+			if validationErr != nil {
+				writeError(ginctx, newCtx, toError(validationErr, statusCode), nil)
+				return
+			}
+			writeError(ginctx, newCtx, toError(err, statusCode), nil)
+			return
+		}
+	}
+
+	if v, ok := in.(InputValidator); ok {
+		if err := v.Validate(newCtx); err != nil {
+			logger.Warn(newCtx, "invalid input. %s", err.Error())
+			writeError(ginctx, newCtx, toError(err, http.StatusUnprocessableEntity), nil)
 			return
 		}
 	}
 
+	if v, ok := in.(domain.Versioned); ok {
+		version, present, err := IfMatchVersion(ginctx)
+		if err != nil {
+			writeError(ginctx, newCtx, &Error{Status: http.StatusBadRequest, Code: CodeInvalidIfMatch, Detail: err.Error()}, nil)
+			return
+		}
+		if present {
+			v.SetVersion(version)
+		}
+	}
+
 	handlerResponse := routeHttp.Handler(newCtx, in, ginctx)
 	logger.Debug(newCtx, "handler response status code %d. error: %v", handlerResponse.StatusCode, handlerResponse.Error)
+	if handlerResponse.Error != nil {
+		e := toError(handlerResponse.Error, handlerResponse.StatusCode)
+		// errors returned along with a success status are informative, the response is still rendered as success
+		if e.Status >= http.StatusBadRequest {
+			logger.Warn(newCtx, "handler error. %s", handlerResponse.Error.Error())
+			writeHeaders(ginctx, handlerResponse.Headers)
+			writeError(ginctx, newCtx, e, handlerResponse.Data)
+			return
+		}
+	}
+
+	if writeUnwrapped(ginctx, newCtx, handlerResponse) {
+		return
+	}
+
 	baseHttpResponse := BaseHttpResponse{}
 	baseHttpResponse.Data = handlerResponse.Data
 	if handlerResponse.Error != nil {
 		baseHttpResponse.ErrorMessage = handlerResponse.Error.Error()
 	}
 
-	ginctx.JSON(handlerResponse.StatusCode, baseHttpResponse)
+	if !acceptable(ginctx) {
+		writeError(ginctx, newCtx, &Error{
+			Status: http.StatusNotAcceptable,
+			Code:   CodeNotAcceptable,
+			Detail: "no acceptable media type in " + ginctx.GetHeader("Accept"),
+		}, nil)
+		return
+	}
+
+	if v, ok := versioned(handlerResponse.Data); ok && handlerResponse.Error == nil {
+		ginctx.Header("ETag", ETag(v.GetVersion()))
+	}
+
+	render(ginctx, handlerResponse.StatusCode, baseHttpResponse)
+}
+
+// writeUnwrapped send raw, streamed, redirect and no content responses, returning false for responses to be wrapped
+// in BaseHttpResponse
+func writeUnwrapped(ginctx *gin.Context, ctx context.Context, handlerResponse *HandlerHttpResponse) bool {
+	writeHeaders(ginctx, handlerResponse.Headers)
+
+	if handlerResponse.Location != "" {
+		statusCode := handlerResponse.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusFound
+		}
+		// gin only redirects with 3xx and 201, other statuses like 202 just carry the Location header
+		if (statusCode >= http.StatusMultipleChoices && statusCode <= http.StatusPermanentRedirect) ||
+			statusCode == http.StatusCreated {
+			ginctx.Redirect(statusCode, handlerResponse.Location)
+			return true
+		}
+		ginctx.Header("Location", handlerResponse.Location)
+	}
+
+	contentType := handlerResponse.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	switch {
+	case handlerResponse.Body != nil:
+		ginctx.Data(handlerResponse.StatusCode, contentType, handlerResponse.Body)
+	case handlerResponse.Stream != nil:
+		if closer, ok := handlerResponse.Stream.(io.Closer); ok {
+			defer closer.Close()
+		}
+		contentLength := handlerResponse.ContentLength
+		if contentLength <= 0 {
+			contentLength = -1
+		}
+		ginctx.DataFromReader(handlerResponse.StatusCode, contentLength, contentType, handlerResponse.Stream, nil)
+		if err := ginctx.Request.Context().Err(); err != nil {
+			logger.Warn(ctx, "stream interrupted by client. %s", err.Error())
+		}
+	case handlerResponse.StatusCode == http.StatusNoContent || handlerResponse.StatusCode == http.StatusNotModified:
+		ginctx.Status(handlerResponse.StatusCode)
+	default:
+		return false
+	}
+	return true
+}
+
+// writeHeaders add headers to the response
+func writeHeaders(ginctx *gin.Context, headers http.Header) {
+	for k, values := range headers {
+		for _, v := range values {
+			ginctx.Writer.Header().Add(k, v)
+		}
+	}
+}
+
+// requestContext return ctx with correlation id, principal and tenant of the request. When the tenant can not be
+// resolved the error response is written and ok is false
+func requestContext(ginctx *gin.Context, ctx context.Context) (newCtx context.Context, ok bool) {
+	newCtx = context.WithValue(ctx, global.CorrelationID, ginctx.GetHeader(global.CorrelationID))
+	if principal := ginctx.GetString(global.Principal); principal != "" {
+		newCtx = security.WithPrincipal(newCtx, principal)
+	}
+
+	// path params are kept in the request context to be read by param package
+	ginctx.Request = ginctx.Request.WithContext(context.WithValue(ginctx.Request.Context(), global.PathParams, ginctx.Params))
+
+	if config.TenantEnabled() {
+		// claims are set by the auth middleware only after the token is validated, unsigned tokens never reach here
+		var claims map[string]any
+		if v, ok := ginctx.Get(global.Claims); ok {
+			claims, _ = v.(map[string]any)
+		}
+		tenantID, err := tenant.Resolve(ginctx.Request, claims)
+		if err != nil {
+			logger.Warn(newCtx, "error resolving tenant. %s", err.Error())
+			writeError(ginctx, newCtx, &Error{Status: http.StatusForbidden, Code: CodeTenantForbidden, Detail: err.Error()}, nil)
+			return nil, false
+		}
+		newCtx = tenant.WithTenant(newCtx, tenantID)
+	}
+	return newCtx, true
 }
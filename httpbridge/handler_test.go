@@ -0,0 +1,69 @@
+package httpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func responseRoute(response *HandlerHttpResponse) *RouteHttp {
+	return &RouteHttp{
+		Method: http.MethodGet,
+		Path:   "/items",
+		Handler: func(ctx context.Context, in any, ginctx *gin.Context) *HandlerHttpResponse {
+			return response
+		},
+	}
+}
+
+func TestHandlerRedirectsOnlyWith3xxAnd201(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *HandlerHttpResponse
+		status   int
+		wrapped  bool
+	}{
+		{"temporary redirect", NewRedirectResponse(http.StatusTemporaryRedirect, "/items/1"), http.StatusTemporaryRedirect, false},
+		{"default status", NewRedirectResponse(0, "/items/1"), http.StatusFound, false},
+		{"created", &HandlerHttpResponse{StatusCode: http.StatusCreated, Location: "/items/1"}, http.StatusCreated, false},
+		{"accepted", &HandlerHttpResponse{StatusCode: http.StatusAccepted, Location: "/items/1", Data: "queued"}, http.StatusAccepted, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveRoute(t, responseRoute(tt.response), httptest.NewRequest(http.MethodGet, "/items", nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, expected %d", w.Code, tt.status)
+			}
+			if location := w.Header().Get("Location"); location != "/items/1" {
+				t.Fatalf("location %q, expected /items/1", location)
+			}
+
+			var body BaseHttpResponse
+			err := json.Unmarshal(w.Body.Bytes(), &body)
+			if tt.wrapped && (err != nil || body.Data != "queued") {
+				t.Fatalf("body %s, expected the data wrapped in BaseHttpResponse", w.Body.String())
+			}
+			if !tt.wrapped && err == nil {
+				t.Fatalf("body %s, expected a redirect", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlerWritesHeadersOnErrors(t *testing.T) {
+	response := NewHandlerHttpResponse(errors.New("slow down"), http.StatusTooManyRequests, nil)
+	response.Headers = http.Header{"Retry-After": {"30"}}
+
+	w := serveRoute(t, responseRoute(response), httptest.NewRequest(http.MethodGet, "/items", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status %d, expected 429", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "30" {
+		t.Fatalf("Retry-After %q, expected 30", retryAfter)
+	}
+}
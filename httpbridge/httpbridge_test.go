@@ -0,0 +1,34 @@
+package httpbridge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rlanhellas/aruna/logger"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logger.SetLogger(zap.NewNop().Sugar())
+	os.Exit(m.Run())
+}
+
+// serveRoute handle req with route registered in a new engine, the way setupHttpServer does
+func serveRoute(t *testing.T, route *RouteHttp, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	t.Cleanup(viper.Reset)
+
+	engine := gin.New()
+	engine.Handle(route.Method, route.Path, func(ginctx *gin.Context) {
+		HttpHandler(ginctx, context.Background(), route)
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,610 @@
+package httpbridge
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/tenant"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed openapiui
+var openAPIUI embed.FS
+
+// OpenAPIUI vendored Swagger UI rendering the document served at openapi.json, relative to the index.html path
+var OpenAPIUI, _ = fs.Sub(openAPIUI, "openapiui")
+
+// OpenAPIDocument OpenAPI 3.1 document describing the routes
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type OpenAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema json schema of a type, Type is a string or a list of strings for nullable types
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 any                       `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Enum                 []any                     `json:"enum,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MinLength            *uint64                   `json:"minLength,omitempty"`
+	MaxLength            *uint64                   `json:"maxLength,omitempty"`
+	MinItems             *uint64                   `json:"minItems,omitempty"`
+	MaxItems             *uint64                   `json:"maxItems,omitempty"`
+}
+
+// builtinSchemaPrefix prefix of the components of httpbridge types, so they never collide with user types
+const builtinSchemaPrefix = "aruna."
+
+var timeType = reflect.TypeOf(time.Time{})
+var builtinPkgPath = reflect.TypeOf(FieldError{}).PkgPath()
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// OpenAPI build the OpenAPI 3.1 document of the route groups, reflecting on route inputs and outputs
+func OpenAPI(groups []*RouteGroupHttp) *OpenAPIDocument {
+	b := &openAPIBuilder{schemas: map[string]*OpenAPISchema{}, types: map[string]reflect.Type{}}
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    OpenAPIInfo{Title: config.AppName(), Version: config.AppVer()},
+		Paths:   map[string]map[string]*OpenAPIOperation{},
+	}
+
+	b.schemaOf(reflect.TypeOf(FieldError{}))
+	b.schemas[b.componentName(reflect.TypeOf(BaseHttpResponse{}))] = envelopeSchema(&OpenAPISchema{})
+	if config.HttpProblemDetails() {
+		b.schemaOf(reflect.TypeOf(ProblemDetails{}))
+	}
+
+	authenticated := false
+	for _, group := range groups {
+		secured := group.Authenticated && config.SecurityEnabled()
+		authenticated = authenticated || secured
+		for _, route := range group.Routes {
+			path := openAPIPath(group.Path + route.Path)
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = map[string]*OpenAPIOperation{}
+			}
+			doc.Paths[path][strings.ToLower(route.Method)] = b.operation(group, route, secured)
+		}
+	}
+
+	doc.Components.Schemas = b.schemas
+	if authenticated {
+		doc.Components.SecuritySchemes = map[string]*OpenAPISecurityScheme{
+			"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		}
+	}
+	return doc
+}
+
+// JSON return the document encoded as json
+func (d *OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML return the document encoded as yaml
+func (d *OpenAPIDocument) YAML() ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportOpenAPI write the document of groups to path, as yaml when path ends with .yaml or .yml and json otherwise
+func ExportOpenAPI(path string, groups []*RouteGroupHttp) error {
+	doc := OpenAPI(groups)
+	encode := doc.JSON
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		encode = doc.YAML
+	}
+
+	b, err := encode()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// openAPIPath turn gin path params like :id and *any into OpenAPI {id} and {any}
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func envelopeSchema(data *OpenAPISchema) *OpenAPISchema {
+	return &OpenAPISchema{
+		Type: "object",
+		Properties: map[string]*OpenAPISchema{
+			"error_message": {Type: "string"},
+			"error_code":    {Type: "string"},
+			"field_errors":  {Type: "array", Items: &OpenAPISchema{Ref: "#/components/schemas/" + builtinSchemaPrefix + "FieldError"}},
+			"data":          data,
+		},
+	}
+}
+
+type openAPIBuilder struct {
+	schemas map[string]*OpenAPISchema
+	types   map[string]reflect.Type
+}
+
+func (b *openAPIBuilder) operation(group *RouteGroupHttp, route *RouteHttp, secured bool) *OpenAPIOperation {
+	fullPath := group.Path + route.Path
+	op := &OpenAPIOperation{
+		OperationID: operationID(route.Method, fullPath),
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   map[string]*OpenAPIResponse{},
+	}
+	if tag := strings.Trim(group.Path, "/"); tag != "" {
+		op.Tags = []string{tag}
+	}
+
+	errorResponse := &OpenAPIResponse{
+		Description: "error",
+		Content: map[string]*OpenAPIMediaType{
+			"application/json": {Schema: &OpenAPISchema{Ref: "#/components/schemas/" + builtinSchemaPrefix + "BaseHttpResponse"}},
+		},
+	}
+	if config.HttpProblemDetails() {
+		errorResponse.Content = map[string]*OpenAPIMediaType{
+			problemContentType: {Schema: &OpenAPISchema{Ref: "#/components/schemas/" + builtinSchemaPrefix + "ProblemDetails"}},
+		}
+	}
+
+	if config.TenantEnabled() && config.TenantResolver() == tenant.ResolverHeader {
+		op.Parameters = append(op.Parameters, &OpenAPIParameter{
+			Name: config.TenantHeader(), In: "header", Required: true, Schema: &OpenAPISchema{Type: "string"},
+		})
+	}
+
+	if route.HandlerInputGenerator != nil {
+		inType := reflect.TypeOf(route.HandlerInputGenerator())
+		op.Parameters = append(op.Parameters, b.parameters(inType)...)
+		op.RequestBody = b.requestBody(route.Method, inType)
+		op.Responses["400"] = errorResponse
+		op.Responses["422"] = errorResponse
+	}
+
+	if secured {
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+		op.Responses["403"] = errorResponse
+	}
+
+	op.Responses["default"] = errorResponse
+
+	data := &OpenAPISchema{}
+	if route.HandlerOutputGenerator != nil {
+		outType := reflect.TypeOf(route.HandlerOutputGenerator())
+		if indirectType(outType) == reflect.TypeOf(HandlerHttpResponse{}) {
+			// raw, streamed and redirect responses have no schema to describe
+			op.Responses["2XX"] = &OpenAPIResponse{Description: "response sent without envelope"}
+			return op
+		}
+		data = b.schemaOf(outType)
+	}
+	status := http.StatusOK
+	if route.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	op.Responses[strconv.Itoa(status)] = &OpenAPIResponse{
+		Description: http.StatusText(status),
+		Content:     map[string]*OpenAPIMediaType{"application/json": {Schema: envelopeSchema(data)}},
+	}
+	return op
+}
+
+func operationID(method, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for _, s := range strings.Split(path, "/") {
+		s = strings.TrimLeft(s, ":*")
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// parameters return the path, query and header parameters declared by the input struct tags
+func (b *openAPIBuilder) parameters(t reflect.Type) []*OpenAPIParameter {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []*OpenAPIParameter
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			params = append(params, b.parameters(sf.Type)...)
+			continue
+		}
+
+		for _, tag := range []string{PathTag, QueryTag, HeaderTag} {
+			name := tagName(sf, tag)
+			if name == "" {
+				continue
+			}
+			schema := b.schemaOf(indirectType(sf.Type))
+			required := applyBinding(schema, sf)
+			params = append(params, &OpenAPIParameter{
+				Name:     name,
+				In:       tag,
+				Required: required || tag == PathTag,
+				Schema:   schema,
+			})
+		}
+	}
+	return params
+}
+
+// requestBody return the json body of the input, or the form body when it declares form tags
+func (b *openAPIBuilder) requestBody(method string, t reflect.Type) *OpenAPIRequestBody {
+	if method == http.MethodGet || method == http.MethodDelete || method == http.MethodHead {
+		return nil
+	}
+
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct {
+		return &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]*OpenAPIMediaType{"application/json": {Schema: b.schemaOf(t)}},
+		}
+	}
+
+	if form, hasFiles := b.formSchema(t); len(form.Properties) > 0 {
+		contentType := "application/x-www-form-urlencoded"
+		if hasFiles {
+			contentType = "multipart/form-data"
+		}
+		return &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]*OpenAPIMediaType{contentType: {Schema: form}},
+		}
+	}
+
+	var schema *OpenAPISchema
+	if hasParamTags(t) {
+		schema = b.structSchema(t, true)
+		if len(schema.Properties) == 0 {
+			return nil
+		}
+	} else {
+		schema = b.schemaOf(t)
+	}
+	return &OpenAPIRequestBody{
+		Required: true,
+		Content:  map[string]*OpenAPIMediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// formSchema return the schema of the fields tagged with form and file, hasFiles tells whether there are file fields
+func (b *openAPIBuilder) formSchema(t reflect.Type) (schema *OpenAPISchema, hasFiles bool) {
+	schema = &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			embedded, embeddedFiles := b.formSchema(sf.Type)
+			for k, v := range embedded.Properties {
+				schema.Properties[k] = v
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			hasFiles = hasFiles || embeddedFiles
+			continue
+		}
+
+		name := tagName(sf, FormTag)
+		if name == "" {
+			if name = tagName(sf, FileTag); name != "" {
+				hasFiles = true
+			}
+		}
+		if name != "" {
+			property := b.schemaOf(indirectType(sf.Type))
+			if applyBinding(property, sf) {
+				schema.Required = append(schema.Required, name)
+			}
+			schema.Properties[name] = property
+		}
+	}
+	return schema, hasFiles
+}
+
+// schemaOf return the schema of t, structs are registered as components and referenced
+func (b *openAPIBuilder) schemaOf(t reflect.Type) *OpenAPISchema {
+	if t == nil {
+		return &OpenAPISchema{}
+	}
+
+	if t.Kind() == reflect.Ptr {
+		return b.schemaOf(t.Elem())
+	}
+
+	if t == timeType {
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	}
+
+	if t == uploadedFileType {
+		return &OpenAPISchema{Type: "string", Format: "binary"}
+	}
+
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+			return &OpenAPISchema{Type: "string"}
+		}
+		return &OpenAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t, false)
+		}
+		name := b.componentName(t)
+		if _, ok := b.schemas[name]; !ok {
+			// registered before building the properties to support recursive types
+			b.schemas[name] = &OpenAPISchema{}
+			*b.schemas[name] = *b.structSchema(t, false)
+		}
+		return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+	default:
+		return &OpenAPISchema{}
+	}
+}
+
+// componentName return the component name of t, prefixed by its package when the name is already taken. httpbridge
+// types are always prefixed by builtinSchemaPrefix
+func (b *openAPIBuilder) componentName(t reflect.Type) string {
+	name := t.Name()
+	if t.PkgPath() == builtinPkgPath {
+		name = builtinSchemaPrefix + name
+	} else if existing, ok := b.types[name]; ok && existing != t {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	b.types[name] = t
+	return name
+}
+
+// structSchema return the schema of the json fields of t, skipParams drop fields bound from path, query, header, form
+// and file
+func (b *openAPIBuilder) structSchema(t reflect.Type, skipParams bool) *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if skipParams && (tagName(sf, PathTag) != "" || tagName(sf, QueryTag) != "" ||
+			tagName(sf, HeaderTag) != "" || tagName(sf, FormTag) != "" || tagName(sf, FileTag) != "") {
+			continue
+		}
+
+		name := strings.SplitN(sf.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" && sf.Anonymous && indirectType(sf.Type).Kind() == reflect.Struct {
+			embedded := b.structSchema(indirectType(sf.Type), skipParams)
+			for k, v := range embedded.Properties {
+				schema.Properties[k] = v
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		property := b.schemaOf(sf.Type)
+		if applyBinding(property, sf) {
+			schema.Required = append(schema.Required, name)
+		}
+		if sf.Type.Kind() == reflect.Ptr {
+			property = nullable(property)
+		}
+		schema.Properties[name] = property
+	}
+	return schema
+}
+
+// applyBinding describe the binding validation tags of sf in schema, returning whether the field is required
+func applyBinding(schema *OpenAPISchema, sf reflect.StructField) bool {
+	required := false
+	for _, rule := range strings.Split(sf.Tag.Get("binding"), ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "email", "uuid":
+			schema.Format = name
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && schema.Type != "string" {
+					schema.Enum = append(schema.Enum, n)
+				} else {
+					schema.Enum = append(schema.Enum, v)
+				}
+			}
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			applyLimit(schema, name, n)
+		}
+	}
+	return required
+}
+
+func applyLimit(schema *OpenAPISchema, rule string, n float64) {
+	u := uint64(n)
+	switch schema.Type {
+	case "string":
+		if rule != "max" {
+			schema.MinLength = &u
+		}
+		if rule != "min" {
+			schema.MaxLength = &u
+		}
+	case "array":
+		if rule != "max" {
+			schema.MinItems = &u
+		}
+		if rule != "min" {
+			schema.MaxItems = &u
+		}
+	case "integer", "number":
+		if rule != "max" {
+			schema.Minimum = &n
+		}
+		if rule != "min" {
+			schema.Maximum = &n
+		}
+	}
+}
+
+// nullable allow null values in schema, references are kept as they are
+func nullable(schema *OpenAPISchema) *OpenAPISchema {
+	if t, ok := schema.Type.(string); ok {
+		schema.Type = []string{t, "null"}
+	}
+	return schema
+}
+
+// hasParamTags return whether some field of t is bound from path, query, header, form or file
+func hasParamTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && indirectType(sf.Type).Kind() == reflect.Struct && hasParamTags(indirectType(sf.Type)) {
+			return true
+		}
+		if tagName(sf, PathTag) != "" || tagName(sf, QueryTag) != "" || tagName(sf, HeaderTag) != "" ||
+			tagName(sf, FormTag) != "" || tagName(sf, FileTag) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func tagName(sf reflect.StructField, tag string) string {
+	name := strings.SplitN(sf.Tag.Get(tag), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
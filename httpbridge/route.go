@@ -0,0 +1,74 @@
+package httpbridge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatusCoder error carrying the HTTP status code to be returned by routes created with Route
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Route create a typed route, In is bound and validated from the request like any RouteHttp input and Out is rendered
+// as BaseHttpResponse data. Errors are mapped to status codes by errorStatusCode
+func Route[In, Out any](method, path string, handler func(ctx context.Context, in In) (Out, error)) *RouteHttp {
+	inType := reflect.TypeOf((*In)(nil)).Elem()
+	inIsPtr := inType.Kind() == reflect.Ptr
+
+	return &RouteHttp{
+		Path:   path,
+		Method: method,
+		HandlerInputGenerator: func() any {
+			if inIsPtr {
+				return reflect.New(inType.Elem()).Interface()
+			}
+			return new(In)
+		},
+		HandlerOutputGenerator: func() any {
+			return new(Out)
+		},
+		Handler: func(ctx context.Context, in any, ginctx *gin.Context) *HandlerHttpResponse {
+			var typedIn In
+			if inIsPtr {
+				typedIn = in.(In)
+			} else {
+				typedIn = *in.(*In)
+			}
+
+			out, err := handler(ctx, typedIn)
+			if err != nil {
+				return NewHandlerHttpResponse(err, errorStatusCode(err), nil)
+			}
+
+			// handlers may return raw, streamed and redirect responses, e.g. Route[In, *HandlerHttpResponse]
+			if response, ok := any(out).(*HandlerHttpResponse); ok && response != nil {
+				return response
+			}
+
+			statusCode := http.StatusOK
+			if method == http.MethodPost {
+				statusCode = http.StatusCreated
+			}
+			return NewHandlerHttpResponse(nil, statusCode, out)
+		},
+	}
+}
+
+// errorStatusCode return the status code of errors implementing StatusCoder, 422 for *ValidationError and 500 otherwise
+func errorStatusCode(err error) int {
+	var statusCoder StatusCoder
+	var validationErr *ValidationError
+	switch {
+	case errors.As(err, &statusCoder):
+		return statusCoder.StatusCode()
+	case errors.As(err, &validationErr):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,92 @@
+package httpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("status %d", e.status)
+}
+
+func (e *statusError) StatusCode() int {
+	return e.status
+}
+
+func TestErrorStatusCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"status coder", &statusError{status: http.StatusConflict}, http.StatusConflict},
+		{"wrapped status coder", fmt.Errorf("saving. %w", &statusError{status: http.StatusNotFound}), http.StatusNotFound},
+		{"validation error", &ValidationError{Fields: []FieldError{{Field: "name"}}}, http.StatusUnprocessableEntity},
+		{"wrapped validation error", fmt.Errorf("checking. %w", &ValidationError{}), http.StatusUnprocessableEntity},
+		{"plain error", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStatusCode(tt.err); got != tt.expected {
+				t.Fatalf("status %d, expected %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+type createItem struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type createdItem struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestRouteRendersTypedOutputAndErrors(t *testing.T) {
+	route := Route(http.MethodPost, "/items", func(ctx context.Context, in *createItem) (*createdItem, error) {
+		if in.Name == "taken" {
+			return nil, &statusError{status: http.StatusConflict}
+		}
+		return &createdItem{Id: 1, Name: in.Name}, nil
+	})
+
+	tests := []struct {
+		body     string
+		expected int
+	}{
+		{`{"name":"one"}`, http.StatusCreated},
+		{`{"name":"taken"}`, http.StatusConflict},
+		{`{}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := serveRoute(t, route, req)
+		if w.Code != tt.expected {
+			t.Fatalf("body %s answered %d, expected %d. %s", tt.body, w.Code, tt.expected, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{"name":"one"}`))
+	req.Header.Set("Content-Type", "application/json")
+	var body struct {
+		Data createdItem `json:"data"`
+	}
+	if err := json.Unmarshal(serveRoute(t, route, req).Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Data.Id != 1 || body.Data.Name != "one" {
+		t.Fatalf("data %+v", body.Data)
+	}
+}
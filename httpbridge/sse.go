@@ -0,0 +1,192 @@
+package httpbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/logger"
+)
+
+// RouteSSE configure a Server-Sent Events route, Handler sends events until it returns or ctx is canceled
+type RouteSSE struct {
+	Path    string
+	Handler func(ctx context.Context, stream *SSEStream) error
+}
+
+// SSEEvent event sent to the client, Data is sent as is when string or []byte and as json otherwise
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  any
+	Retry time.Duration //optional, reconnection time sent to the client
+}
+
+// SSEStream event stream of a connection, safe to be used by many goroutines
+type SSEStream struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	events      chan []byte
+	sendTimeout time.Duration
+	lastEventID string
+}
+
+// Send queue event to be written to the client. When the buffer stays full longer than http.stream.sendtimeout the
+// connection is closed and ErrSlowConsumer is returned
+func (s *SSEStream) Send(event SSEEvent) error {
+	b, err := encodeSSEEvent(event)
+	if err != nil {
+		return err
+	}
+
+	if s.ctx.Err() != nil {
+		return ErrStreamClosed
+	}
+
+	timer := time.NewTimer(s.sendTimeout)
+	defer timer.Stop()
+	select {
+	case s.events <- b:
+		return nil
+	case <-s.ctx.Done():
+		return ErrStreamClosed
+	case <-timer.C:
+		s.cancel()
+		return ErrSlowConsumer
+	}
+}
+
+// LastEventID return the Last-Event-ID sent by the client when reconnecting, empty on the first connection
+func (s *SSEStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done return a channel closed when the stream is closed
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// SSEHandler handler for all Server-Sent Events requests
+func SSEHandler(ginctx *gin.Context, ctx context.Context, route *RouteSSE) {
+	newCtx, ok := requestContext(ginctx, ctx)
+	if !ok {
+		return
+	}
+	logger.Debug(newCtx, "handling sse path %s", route.Path)
+
+	streamCtx, cancel, done, err := streamContext(newCtx, ginctx.Request.Context())
+	if err != nil {
+		writeShuttingDown(ginctx, newCtx)
+		return
+	}
+	defer done()
+
+	stream := &SSEStream{
+		ctx:         streamCtx,
+		cancel:      cancel,
+		events:      make(chan []byte, config.HttpStreamBuffer()),
+		sendTimeout: config.HttpStreamSendTimeout(),
+		lastEventID: ginctx.GetHeader("Last-Event-ID"),
+	}
+
+	w := ginctx.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		writeSSE(streamCtx, cancel, w, stream.events)
+	}()
+
+	if err := route.Handler(streamCtx, stream); err != nil && !errors.Is(err, ErrStreamClosed) {
+		logger.Warn(newCtx, "sse handler error. %s", err.Error())
+	}
+	cancel()
+	<-writerDone
+	logger.Debug(newCtx, "sse stream %s closed", route.Path)
+}
+
+// writeSSE write queued events and heartbeats to w until ctx is canceled, pending events are flushed before returning
+func writeSSE(ctx context.Context, cancel context.CancelFunc, w gin.ResponseWriter, events chan []byte) {
+	heartbeat := time.NewTicker(config.HttpStreamHeartbeat())
+	defer heartbeat.Stop()
+
+	write := func(b []byte) bool {
+		if _, err := w.Write(b); err != nil {
+			cancel()
+			return false
+		}
+		w.Flush()
+		return true
+	}
+
+	for {
+		select {
+		case b := <-events:
+			if !write(b) {
+				return
+			}
+		case <-heartbeat.C:
+			if !write([]byte(": ping\n\n")) {
+				return
+			}
+		case <-ctx.Done():
+			for {
+				select {
+				case b := <-events:
+					if !write(b) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// encodeSSEEvent encode event in the text/event-stream format, multiline data is split in many data fields
+func encodeSSEEvent(event SSEEvent) ([]byte, error) {
+	var data string
+	switch d := event.Data.(type) {
+	case nil:
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		b, err := json.Marshal(d)
+		if err != nil {
+			return nil, fmt.Errorf("can not encode sse event data. %w", err)
+		}
+		data = string(b)
+	}
+
+	var buf bytes.Buffer
+	if event.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", strings.ReplaceAll(event.ID, "\n", ""))
+	}
+	if event.Event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", strings.ReplaceAll(event.Event, "\n", ""))
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&buf, "retry: %d\n", event.Retry.Milliseconds())
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,83 @@
+package httpbridge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrSlowConsumer is returned when sending to a stream whose buffer stayed full longer than http.stream.sendtimeout,
+// the connection is closed
+var ErrSlowConsumer = errors.New("slow consumer, stream closed")
+
+// ErrStreamClosed is returned when sending to a stream already closed by the client, the handler or the shutdown
+var ErrStreamClosed = errors.New("stream closed")
+
+var streamsCtx, closeStreams = context.WithCancel(context.Background())
+var streams sync.WaitGroup
+
+// streamsMu guards streamsClosed, so no stream is added to streams once CloseStreams waits for them
+var streamsMu sync.Mutex
+var streamsClosed bool
+
+// CloseStreams close all SSE and WebSocket connections, refuse new ones and wait until their handlers return or ctx
+// is done
+func CloseStreams(ctx context.Context) error {
+	streamsMu.Lock()
+	streamsClosed = true
+	streamsMu.Unlock()
+	closeStreams()
+
+	done := make(chan struct{})
+	go func() {
+		streams.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// streamContext return ctx canceled when the client disconnects, the streams are closed or cancel is called. The
+// stream is tracked by CloseStreams until done is called. ErrStreamClosed is returned once CloseStreams was called
+func streamContext(ctx context.Context, requestCtx context.Context) (streamCtx context.Context, cancel context.CancelFunc, done func(), err error) {
+	streamsMu.Lock()
+	if streamsClosed {
+		streamsMu.Unlock()
+		return nil, nil, nil, ErrStreamClosed
+	}
+	streams.Add(1)
+	streamsMu.Unlock()
+
+	streamCtx, cancel = context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-streamCtx.Done():
+		case <-requestCtx.Done():
+		case <-streamsCtx.Done():
+		}
+		cancel()
+	}()
+	return streamCtx, cancel, func() {
+		cancel()
+		streams.Done()
+	}, nil
+}
+
+// writeShuttingDown answer a stream request arriving after CloseStreams with 503
+func writeShuttingDown(ginctx *gin.Context, ctx context.Context) {
+	ginctx.Header("Connection", "close")
+	writeError(ginctx, ctx, &Error{Status: http.StatusServiceUnavailable, Code: CodeShuttingDown, Detail: "server is shutting down"}, nil)
+}
+
+// shuttingDown return true when the streams are being closed by CloseStreams
+func shuttingDown() bool {
+	return streamsCtx.Err() != nil
+}
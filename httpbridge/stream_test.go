@@ -0,0 +1,96 @@
+package httpbridge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rlanhellas/aruna/global"
+	"github.com/spf13/viper"
+)
+
+// resetStreams reopen the streams closed by a test calling CloseStreams
+func resetStreams(t *testing.T) {
+	t.Cleanup(func() {
+		streamsMu.Lock()
+		defer streamsMu.Unlock()
+		streamsCtx, closeStreams = context.WithCancel(context.Background())
+		streamsClosed = false
+	})
+}
+
+func TestStreamsAreRefusedAfterCloseStreams(t *testing.T) {
+	resetStreams(t)
+	if err := CloseStreams(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := streamContext(context.Background(), context.Background()); err != ErrStreamClosed {
+		t.Fatalf("error %v, expected ErrStreamClosed", err)
+	}
+
+	handled := false
+	route := &RouteSSE{Path: "/events", Handler: func(ctx context.Context, stream *SSEStream) error {
+		handled = true
+		return nil
+	}}
+	engine := gin.New()
+	engine.GET(route.Path, func(ginctx *gin.Context) {
+		SSEHandler(ginctx, context.Background(), route)
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	if w.Code != http.StatusServiceUnavailable || handled {
+		t.Fatalf("status %d, handled %t, expected 503 without calling the handler", w.Code, handled)
+	}
+}
+
+func TestWebSocketClosesConnectionOnMessagesOverReadLimit(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set(global.HttpStreamReadMax, "16")
+
+	route := &RouteWebSocket{Path: "/ws", Handler: func(ctx context.Context, conn *WebSocketConn) error {
+		for {
+			msg, err := conn.ReceiveRaw()
+			if err != nil {
+				return nil
+			}
+			if err := conn.SendRaw(msg.Type, msg.Data); err != nil {
+				return nil
+			}
+		}
+	}}
+	engine := gin.New()
+	engine.GET(route.Path, func(ginctx *gin.Context) {
+		WebSocketHandler(ginctx, context.Background(), route)
+	})
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := ws.WriteMessage(websocket.TextMessage, []byte("small")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := ws.ReadMessage(); err != nil || string(data) != "small" {
+		t.Fatalf("echo %q, error %v", data, err)
+	}
+
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 64))); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ws.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("error %v, expected the connection closed with message too big", err)
+	}
+}
@@ -0,0 +1,213 @@
+package httpbridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/storage"
+)
+
+// Stable codes of the upload errors
+const (
+	CodePayloadTooLarge     = "payload_too_large"
+	CodeFileTooLarge        = "file_too_large"
+	CodeUnsupportedFileType = "unsupported_file_type"
+)
+
+var uploadedFileType = reflect.TypeOf(UploadedFile{})
+
+// UploadedFile file of a multipart request, bound to *UploadedFile and []*UploadedFile fields tagged with file, e.g.
+// `file:"avatar,maxsize=2MB,types=image/png|image/jpeg"`. maxsize and types override http.upload.maxfilesize and
+// http.upload.allowedtypes
+type UploadedFile struct {
+	Filename    string //base name sent by the client, do not use it as storage key
+	Size        int64
+	ContentType string //sniffed from the content, the type declared by the client is ignored
+	header      *multipart.FileHeader
+}
+
+// Open open the file content, it must be closed by the caller
+func (f *UploadedFile) Open() (multipart.File, error) {
+	return f.header.Open()
+}
+
+// Save store the file in the configured storage under a random key with prefix, see storage.NewKey
+func (f *UploadedFile) Save(ctx context.Context, prefix string) (*storage.Object, error) {
+	file, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return storage.Put(ctx, storage.NewKey(prefix, f.Filename), file, f.ContentType)
+}
+
+// fileRule limits of a file field
+type fileRule struct {
+	maxSize int64
+	types   []string
+}
+
+// parseFileTag return the field name and limits of a file tag
+func parseFileTag(tag string) (string, fileRule, error) {
+	parts := strings.Split(tag, ",")
+	rule := fileRule{maxSize: config.HttpUploadMaxFileSize(), types: config.HttpUploadAllowedTypes()}
+	for _, option := range parts[1:] {
+		key, value, _ := strings.Cut(option, "=")
+		switch strings.TrimSpace(key) {
+		case "maxsize":
+			size, err := parseSize(value)
+			if err != nil {
+				return "", rule, fmt.Errorf("invalid maxsize %s of file %s. %w", value, parts[0], err)
+			}
+			rule.maxSize = size
+		case "types":
+			rule.types = strings.Split(value, "|")
+		}
+	}
+	return parts[0], rule, nil
+}
+
+// parseSize parse sizes like 512, 100KB, 2MB and 1GB
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for suffix, m := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if strings.HasSuffix(s, suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, suffix)), m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(s, "B"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
+
+// bindFiles set the fields of the struct v tagged with file from form, checking their size and sniffed type
+func bindFiles(v reflect.Value, form *multipart.Form) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := bindFiles(v.Field(i), form); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tag := sf.Tag.Get(FileTag)
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name, rule, err := parseFileTag(tag)
+		if err != nil {
+			return err
+		}
+
+		var headers []*multipart.FileHeader
+		if form != nil {
+			headers = form.File[name]
+		}
+		if len(headers) == 0 {
+			continue
+		}
+
+		files := make([]*UploadedFile, 0, len(headers))
+		for _, header := range headers {
+			file, err := newUploadedFile(name, header, rule)
+			if err != nil {
+				return err
+			}
+			files = append(files, file)
+		}
+
+		field := v.Field(i)
+		switch {
+		case sf.Type == reflect.PtrTo(uploadedFileType):
+			field.Set(reflect.ValueOf(files[0]))
+		case sf.Type == reflect.SliceOf(reflect.PtrTo(uploadedFileType)):
+			field.Set(reflect.ValueOf(files))
+		default:
+			return fmt.Errorf("file %s must be bound to *UploadedFile or []*UploadedFile, not %s", name, sf.Type.String())
+		}
+	}
+	return nil
+}
+
+// newUploadedFile check header against rule sniffing its content type
+func newUploadedFile(name string, header *multipart.FileHeader, rule fileRule) (*UploadedFile, error) {
+	if rule.maxSize > 0 && header.Size > rule.maxSize {
+		return nil, &Error{
+			Status: http.StatusRequestEntityTooLarge,
+			Code:   CodeFileTooLarge,
+			Detail: fmt.Sprintf("file %s exceeds %d bytes", name, rule.maxSize),
+			Fields: []FieldError{{Field: name, Code: "max_size", Message: fmt.Sprintf("must have at most %d bytes", rule.maxSize)}},
+		}
+	}
+
+	contentType, err := sniffContentType(header)
+	if err != nil {
+		return nil, &errMalformedBody{err: err}
+	}
+	if !typeAllowed(contentType, rule.types) {
+		return nil, &Error{
+			Status: http.StatusUnsupportedMediaType,
+			Code:   CodeUnsupportedFileType,
+			Detail: fmt.Sprintf("file %s of type %s is not allowed", name, contentType),
+			Fields: []FieldError{{Field: name, Code: "type", Message: "must be one of " + strings.Join(rule.types, ", ")}},
+		}
+	}
+
+	return &UploadedFile{
+		Filename:    filepath.Base(strings.ReplaceAll(header.Filename, "\\", "/")),
+		Size:        header.Size,
+		ContentType: contentType,
+		header:      header,
+	}, nil
+}
+
+// sniffContentType detect the content type from the first 512 bytes of the file
+func sniffContentType(header *multipart.FileHeader) (string, error) {
+	f, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	b := make([]byte, 512)
+	n, err := io.ReadFull(f, b)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(b[:n]), ";")
+	return contentType, nil
+}
+
+// typeAllowed return whether contentType matches one of allowed, like image/png or image/*. Empty allowed allows all
+func typeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		a = strings.TrimSpace(a)
+		if a == "*/*" || strings.EqualFold(a, contentType) ||
+			(strings.HasSuffix(a, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package httpbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rlanhellas/aruna/global"
+	"github.com/spf13/viper"
+)
+
+const pngHeader = "\x89PNG\r\n\x1a\n"
+
+type uploadInput struct {
+	Avatar *UploadedFile   `file:"avatar,maxsize=100,types=image/png"`
+	Docs   []*UploadedFile `file:"docs"`
+}
+
+func uploadRoute(bound *uploadInput) *RouteHttp {
+	return &RouteHttp{
+		Method:                http.MethodPost,
+		Path:                  "/uploads",
+		HandlerInputGenerator: func() any { return &uploadInput{} },
+		Handler: func(ctx context.Context, in any, ginctx *gin.Context) *HandlerHttpResponse {
+			*bound = *in.(*uploadInput)
+			return NewHandlerHttpResponse(nil, http.StatusNoContent, nil)
+		},
+	}
+}
+
+// multipartRequest build a multipart request sending files, keyed by field name and then by file name
+func multipartRequest(t *testing.T, files map[string]map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for field, named := range files {
+		for filename, content := range named {
+			part, err := writer.CreateFormFile(field, filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = part.Write([]byte(content))
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/uploads", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadBindsFiles(t *testing.T) {
+	req := multipartRequest(t, map[string]map[string]string{
+		"avatar": {"../../avatar.png": pngHeader + "pixels"},
+		"docs":   {"notes.txt": "some notes"},
+	})
+
+	var bound uploadInput
+	w := serveRoute(t, uploadRoute(&bound), req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status %d, expected 204. %s", w.Code, w.Body.String())
+	}
+	if bound.Avatar == nil || bound.Avatar.Filename != "avatar.png" || bound.Avatar.ContentType != "image/png" ||
+		bound.Avatar.Size != int64(len(pngHeader)+6) {
+		t.Fatalf("avatar %+v", bound.Avatar)
+	}
+	if len(bound.Docs) != 1 || bound.Docs[0].ContentType != "text/plain" {
+		t.Fatalf("docs %+v", bound.Docs)
+	}
+}
+
+func TestUploadRejectsFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]map[string]string
+		status int
+		code   string
+	}{
+		{"file over maxsize", map[string]map[string]string{"avatar": {"avatar.png": pngHeader + strings.Repeat("x", 100)}},
+			http.StatusRequestEntityTooLarge, CodeFileTooLarge},
+		// the type declared by the client is ignored, text is sniffed from the content
+		{"type not allowed", map[string]map[string]string{"avatar": {"avatar.png": "plain text"}},
+			http.StatusUnsupportedMediaType, CodeUnsupportedFileType},
+		{"body over maxbodysize", map[string]map[string]string{"docs": {"big.txt": strings.Repeat("x", 2048)}},
+			http.StatusRequestEntityTooLarge, CodePayloadTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set(global.HttpUploadMaxBody, "1KB")
+			var bound uploadInput
+			w := serveRoute(t, uploadRoute(&bound), multipartRequest(t, tt.files))
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, expected %d. %s", w.Code, tt.status, w.Body.String())
+			}
+			var body BaseHttpResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.ErrorCode != tt.code {
+				t.Fatalf("error code %q, expected %q", body.ErrorCode, tt.code)
+			}
+		})
+	}
+}
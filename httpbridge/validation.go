@@ -0,0 +1,147 @@
+package httpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError invalid field of the request input
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Code    string `json:"code" xml:"code"`
+	Message string `json:"message" xml:"message"`
+}
+
+// ValidationError is returned when the request input is invalid, listing each invalid field
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, fmt.Sprintf("%s %s", f.Field, f.Message))
+	}
+	return "invalid input: " + strings.Join(messages, ", ")
+}
+
+// InputValidator input implementing it is validated after struct tag validation and before RouteHttp.Handler runs,
+// meant for cross-field and business validation. Return a *ValidationError to list the invalid fields
+type InputValidator interface {
+	Validate(ctx context.Context) error
+}
+
+var validatorOnce sync.Once
+var validationMessages = map[string]string{
+	"required": "is required",
+	"email":    "must be a valid email",
+	"uuid":     "must be a valid UUID",
+	"min":      "must be at least %s",
+	"max":      "must be at most %s",
+	"len":      "must have length %s",
+	"oneof":    "must be one of [%s]",
+}
+var validationMessagesMu sync.RWMutex
+
+// RegisterValidation register a custom validator used by binding struct tags, message is shown for invalid fields and
+// may contain %s to be replaced by the tag param. Must be called at startup, before serving requests
+func RegisterValidation(tag string, fn validator.Func, message string) error {
+	if err := validatorEngine().RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+
+	validationMessagesMu.Lock()
+	defer validationMessagesMu.Unlock()
+	validationMessages[tag] = message
+	return nil
+}
+
+// validatorEngine return the validator used by gin binding, reporting fields by their json name or the name of the
+// tag binding them
+func validatorEngine() *validator.Validate {
+	v := binding.Validator.Engine().(*validator.Validate)
+	validatorOnce.Do(func() {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+			for _, tag := range []string{PathTag, QueryTag, HeaderTag, FormTag, FileTag} {
+				if name = tagName(f, tag); name != "" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	})
+	return v
+}
+
+// bindError translate a binding error into the response status and the invalid fields, validationErr is nil when the
+// body could not be decoded at all
+func bindError(err error) (statusCode int, validationErr *ValidationError) {
+	var fields []FieldError
+	switch e := err.(type) {
+	case validator.ValidationErrors:
+		fields = validationFields("", e)
+	case binding.SliceValidationError:
+		for i, item := range e {
+			var ve validator.ValidationErrors
+			if errors.As(item, &ve) {
+				fields = append(fields, validationFields(fmt.Sprintf("[%d].", i), ve)...)
+			}
+		}
+	case *ValidationError:
+		return http.StatusBadRequest, e
+	case *json.UnmarshalTypeError:
+		return http.StatusBadRequest, &ValidationError{Fields: []FieldError{{
+			Field:   e.Field,
+			Code:    "type",
+			Message: fmt.Sprintf("must be of type %s", e.Type.String()),
+		}}}
+	default:
+		return http.StatusBadRequest, nil
+	}
+	return http.StatusUnprocessableEntity, &ValidationError{Fields: fields}
+}
+
+func validationFields(prefix string, errs validator.ValidationErrors) []FieldError {
+	validationMessagesMu.RLock()
+	defer validationMessagesMu.RUnlock()
+
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		// namespace starts with the root struct name, which is not part of the request
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+
+		message, ok := validationMessages[fe.Tag()]
+		if !ok {
+			message = fmt.Sprintf("failed on %s validation", fe.Tag())
+		}
+		if strings.Contains(message, "%s") {
+			message = fmt.Sprintf(message, fe.Param())
+		}
+
+		fields = append(fields, FieldError{
+			Field:   prefix + field,
+			Code:    fe.Tag(),
+			Message: message,
+		})
+	}
+	return fields
+}
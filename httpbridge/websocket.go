@@ -0,0 +1,236 @@
+package httpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/logger"
+)
+
+const wsWriteWait = 10 * time.Second
+
+// RouteWebSocket configure a WebSocket route, Handler exchanges messages until it returns or ctx is canceled
+type RouteWebSocket struct {
+	Path    string
+	Handler func(ctx context.Context, conn *WebSocketConn) error
+}
+
+// WebSocketMessage message received from the client, Type is websocket.TextMessage or websocket.BinaryMessage
+type WebSocketMessage struct {
+	Type int
+	Data []byte
+}
+
+// WebSocketConn connection of a WebSocket route, Send is safe to be used by many goroutines and Receive by one
+type WebSocketConn struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	out         chan WebSocketMessage
+	in          chan WebSocketMessage
+	sendTimeout time.Duration
+}
+
+// Send queue v encoded as json in a text message, see SendRaw
+func (c *WebSocketConn) Send(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.SendRaw(websocket.TextMessage, b)
+}
+
+// SendRaw queue a message to be written to the client. When the buffer stays full longer than http.stream.sendtimeout
+// the connection is closed and ErrSlowConsumer is returned
+func (c *WebSocketConn) SendRaw(messageType int, data []byte) error {
+	if c.ctx.Err() != nil {
+		return ErrStreamClosed
+	}
+
+	timer := time.NewTimer(c.sendTimeout)
+	defer timer.Stop()
+	select {
+	case c.out <- WebSocketMessage{Type: messageType, Data: data}:
+		return nil
+	case <-c.ctx.Done():
+		return ErrStreamClosed
+	case <-timer.C:
+		c.cancel()
+		return ErrSlowConsumer
+	}
+}
+
+// Receive wait for the next message and decode it as json into v
+func (c *WebSocketConn) Receive(v any) error {
+	msg, err := c.ReceiveRaw()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(msg.Data, v)
+}
+
+// ReceiveRaw wait for the next message, ErrStreamClosed is returned once the connection is closed
+func (c *WebSocketConn) ReceiveRaw() (WebSocketMessage, error) {
+	select {
+	case msg, ok := <-c.in:
+		if !ok {
+			return WebSocketMessage{}, ErrStreamClosed
+		}
+		return msg, nil
+	case <-c.ctx.Done():
+		return WebSocketMessage{}, ErrStreamClosed
+	}
+}
+
+// Done return a channel closed when the connection is closed
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+// WebSocketHandler handler for all WebSocket requests
+func WebSocketHandler(ginctx *gin.Context, ctx context.Context, route *RouteWebSocket) {
+	newCtx, ok := requestContext(ginctx, ctx)
+	if !ok {
+		return
+	}
+	logger.Debug(newCtx, "handling websocket path %s", route.Path)
+
+	streamCtx, cancel, done, err := streamContext(newCtx, context.Background())
+	if err != nil {
+		writeShuttingDown(ginctx, newCtx)
+		return
+	}
+	defer done()
+
+	upgrader := websocket.Upgrader{CheckOrigin: checkOrigin}
+	ws, err := upgrader.Upgrade(ginctx.Writer, ginctx.Request, nil)
+	if err != nil {
+		// the upgrader already wrote the error response
+		logger.Warn(newCtx, "error upgrading websocket connection. %s", err.Error())
+		return
+	}
+	defer ws.Close()
+	ws.SetReadLimit(config.HttpStreamReadLimit())
+
+	buffer := config.HttpStreamBuffer()
+	conn := &WebSocketConn{
+		ctx:         streamCtx,
+		cancel:      cancel,
+		out:         make(chan WebSocketMessage, buffer),
+		in:          make(chan WebSocketMessage, buffer),
+		sendTimeout: config.HttpStreamSendTimeout(),
+	}
+
+	heartbeat := config.HttpStreamHeartbeat()
+	go readWebSocket(streamCtx, cancel, ws, conn.in, heartbeat)
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		writeWebSocket(streamCtx, cancel, ws, conn.out, heartbeat)
+	}()
+
+	if err := route.Handler(streamCtx, conn); err != nil && !errors.Is(err, ErrStreamClosed) {
+		logger.Warn(newCtx, "websocket handler error. %s", err.Error())
+	}
+	cancel()
+	<-writerDone
+	logger.Debug(newCtx, "websocket connection %s closed", route.Path)
+}
+
+// readWebSocket read client messages into in until the connection fails, a missing pong after two heartbeats closes it.
+// Messages larger than http.stream.readlimit close the connection
+func readWebSocket(ctx context.Context, cancel context.CancelFunc, ws *websocket.Conn, in chan WebSocketMessage, heartbeat time.Duration) {
+	defer cancel()
+	defer close(in)
+
+	_ = ws.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	})
+
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		select {
+		case in <- WebSocketMessage{Type: messageType, Data: data}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeWebSocket write queued messages and pings until ctx is canceled, then flush pending messages and send the close
+// frame, going away when the server is shutting down
+func writeWebSocket(ctx context.Context, cancel context.CancelFunc, ws *websocket.Conn, out chan WebSocketMessage, heartbeat time.Duration) {
+	ping := time.NewTicker(heartbeat)
+	defer ping.Stop()
+
+	write := func(msg WebSocketMessage) bool {
+		_ = ws.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := ws.WriteMessage(msg.Type, msg.Data); err != nil {
+			cancel()
+			return false
+		}
+		return true
+	}
+
+	for {
+		select {
+		case msg := <-out:
+			if !write(msg) {
+				return
+			}
+		case <-ping.C:
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				cancel()
+				return
+			}
+		case <-ctx.Done():
+			for {
+				select {
+				case msg := <-out:
+					if !write(msg) {
+						return
+					}
+				default:
+					closeCode := websocket.CloseNormalClosure
+					if shuttingDown() {
+						closeCode = websocket.CloseGoingAway
+					}
+					_ = ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, ""),
+						time.Now().Add(wsWriteWait))
+					return
+				}
+			}
+		}
+	}
+}
+
+// checkOrigin allow same origin requests, requests without Origin and the origins in http.stream.allowedorigins
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	for _, allowed := range config.HttpStreamAllowedOrigins() {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
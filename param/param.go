@@ -2,17 +2,19 @@ package param
 
 import (
 	"errors"
-	"github.com/go-chi/chi/v5"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rlanhellas/aruna/global"
 )
 
 var ErrParam = errors.New("error parsing param")
 
 func UInt64(r *http.Request, param string) (uint64, error) {
-	val, err := strconv.ParseInt(chi.URLParam(r, param), 10, 64)
+	val, err := strconv.ParseInt(URLParam(r, param), 10, 64)
 	if err != nil {
 		return 0, ErrParam
 	}
@@ -21,7 +23,7 @@ func UInt64(r *http.Request, param string) (uint64, error) {
 }
 
 func Int64(r *http.Request, param string) (int64, error) {
-	val, err := strconv.ParseInt(chi.URLParam(r, param), 10, 64)
+	val, err := strconv.ParseInt(URLParam(r, param), 10, 64)
 	if err != nil {
 		return 0, ErrParam
 	}
@@ -30,7 +32,13 @@ func Int64(r *http.Request, param string) (int64, error) {
 }
 
 func String(r *http.Request, param string) string {
-	return chi.URLParam(r, param)
+	return URLParam(r, param)
+}
+
+// URLParam return the path param of the gin route handling r, empty when missing
+func URLParam(r *http.Request, param string) string {
+	params, _ := r.Context().Value(global.PathParams).(gin.Params)
+	return params.ByName(param)
 }
 
 // ToStrSlice turn comma separated query param to str slice
@@ -2,42 +2,75 @@ package aruna
 
 import (
 	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/db"
 	"github.com/rlanhellas/aruna/httpbridge"
+	"github.com/rlanhellas/aruna/logger"
+	"github.com/rlanhellas/aruna/storage"
 )
 
 // RunRequest contains all configuration to run your app
 type RunRequest struct {
-	RoutesGroup    []*httpbridge.RouteGroupHttp
-	MigrateTables  []any
-	BackgroundTask func(ctx context.Context)
+	RoutesGroup     []*httpbridge.RouteGroupHttp
+	MigrateTables   []any
+	Migrations      fs.FS      //versioned sql migrations (e.g. embed.FS) named like 0001_create_users.up.sql and 0001_create_users.down.sql
+	Seeds           []*db.Seed //reference data applied once after migrations in the configured profiles
+	BackgroundTask  func(ctx context.Context)
+	OutboxPublisher db.OutboxPublisher //when set, outbox messages are dispatched to it in background
+	Storage         storage.Storage    //persist uploads, overrides the local storage configured by storage.local.root
 }
 
 // Run Starts the application
 func Run(req *RunRequest) {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	setupConfig()
 	setupLogger()
 
 	//go setupMetrics()
 
+	if config.DbEnabled() && config.DbMigrateOnly() {
+		setupDB(ctx, req)
+		logger.Info(ctx, "migrate only mode, database migrated successfully")
+		return
+	}
+
+	setupStorage(req)
+
 	if req.BackgroundTask != nil {
 		go req.BackgroundTask(ctx)
 	}
 
+	var server *http.Server
 	if config.HttpServerEnabled() {
-		go setupHttpServer(req.RoutesGroup, ctx)
+		server = setupHttpServer(req.RoutesGroup, ctx)
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				panic(err)
+			}
+		}()
 	}
 
 	if config.DbEnabled() {
-		setupDB(ctx, req.MigrateTables)
+		setupDB(ctx, req)
 	}
 
 	//setupAuthZAuthN()
 
-	//run forever
-	for {
-		select {}
+	//run until SIGINT or SIGTERM
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	logger.Info(ctx, "received %s, shutting down", sig.String())
+
+	if server != nil {
+		shutdownHttpServer(ctx, server)
 	}
 }
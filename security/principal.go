@@ -0,0 +1,35 @@
+package security
+
+import (
+	"context"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/global"
+)
+
+// WithPrincipal return a context bound to the authenticated principal
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, global.Principal, principal)
+}
+
+// Principal return the authenticated principal bound to ctx, empty when there is none
+func Principal(ctx context.Context) string {
+	if principal, ok := ctx.Value(global.Principal).(string); ok {
+		return principal
+	}
+	return ""
+}
+
+// PrincipalFromToken return the principal claim of an access token already checked by ValidateJwt
+func PrincipalFromToken(accessToken string) string {
+	claims, err := Claims(accessToken)
+	if err != nil {
+		return ""
+	}
+
+	if principal, ok := claims[config.SecurityPrincipalClaim()].(string); ok && principal != "" {
+		return principal
+	}
+	principal, _ := claims["sub"].(string)
+	return principal
+}
@@ -112,6 +112,17 @@ func ValidateJwt(ctx context.Context, accessToken string) bool {
 	return r.Valid
 }
 
+// Claims return the claims of access token without validating it, use it only on tokens already checked by ValidateJwt
+func Claims(accessToken string) (jwt.MapClaims, error) {
+	accessToken = strings.ReplaceAll(accessToken, "Bearer ", "")
+	claims := jwt.MapClaims{}
+	_, _, err := new(jwt.Parser).ParseUnverified(accessToken, claims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
 func publicKeyFrom64(jwk *JwkKey) (*rsa.PublicKey, error) {
 
 	// Create the RSA public key.
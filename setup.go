@@ -2,11 +2,17 @@ package aruna
 
 import (
 	"context"
+	"database/sql"
+	"expvar"
 	"fmt"
+	"io/fs"
+	"mime"
 	"net/http"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rlanhellas/aruna/config"
@@ -15,12 +21,10 @@ import (
 	"github.com/rlanhellas/aruna/httpbridge"
 	"github.com/rlanhellas/aruna/logger"
 	"github.com/rlanhellas/aruna/security"
+	"github.com/rlanhellas/aruna/storage"
 	"github.com/spf13/viper"
-	swaggerfiles "github.com/swaggo/files"
-	ginswagger "github.com/swaggo/gin-swagger"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	loggergorm "gorm.io/gorm/logger"
 )
@@ -93,22 +97,15 @@ func setupConfig() {
 	}
 }
 func setupMetrics() {}
-func setupHttpServer(routesGroup []*httpbridge.RouteGroupHttp, ctx context.Context) {
+
+// setupHttpServer create the http server with all routes, it is started and shut down by Run
+func setupHttpServer(routesGroup []*httpbridge.RouteGroupHttp, ctx context.Context) *http.Server {
 	r := gin.Default()
 
 	for _, group := range routesGroup {
 		g := r.Group(group.Path)
 		if group.Authenticated && config.SecurityEnabled() {
-			g.Use(func(c *gin.Context) {
-				authHeader := c.GetHeader("Authorization")
-				if authHeader == "" {
-					c.AbortWithStatus(http.StatusForbidden)
-				}
-
-				if !security.ValidateJwt(ctx, authHeader) {
-					c.AbortWithStatus(http.StatusForbidden)
-				}
-			})
+			g.Use(authMiddleware(ctx))
 		}
 
 		mapRoutes := make(map[string]*httpbridge.RouteHttp, len(group.Routes))
@@ -122,49 +119,280 @@ func setupHttpServer(routesGroup []*httpbridge.RouteGroupHttp, ctx context.Conte
 				}
 			})
 		}
+
+		for _, route := range group.SSERoutes {
+			route := route
+			g.GET(route.Path, func(ginctx *gin.Context) {
+				httpbridge.SSEHandler(ginctx, ctx, route)
+			})
+		}
+
+		for _, route := range group.WebSocketRoutes {
+			route := route
+			g.GET(route.Path, func(ginctx *gin.Context) {
+				httpbridge.WebSocketHandler(ginctx, ctx, route)
+			})
+		}
+	}
+
+	setupOpenAPI(ctx, r, routesGroup)
+	// expvar publishes the command line and memory stats, so it is only served when enabled and behind authentication
+	if config.HttpDebugVars() {
+		debug := r.Group("/debug")
+		if config.SecurityEnabled() {
+			debug.Use(authMiddleware(ctx))
+		}
+		debug.GET("/vars", gin.WrapH(expvar.Handler()))
 	}
 
-	r.GET("/doc/*any", ginswagger.WrapHandler(swaggerfiles.Handler))
+	return &http.Server{
+		Addr:    "0.0.0.0:" + strconv.Itoa(config.HttpServerPort()),
+		Handler: r,
+	}
+}
+
+// authMiddleware reject requests without a valid JWT, setting the principal and the validated claims of the others
+func authMiddleware(ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		if !security.ValidateJwt(ctx, authHeader) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Set(global.Principal, security.PrincipalFromToken(authHeader))
+		if claims, err := security.Claims(authHeader); err == nil {
+			c.Set(global.Claims, map[string]any(claims))
+		}
+	}
+}
+
+// shutdownHttpServer close the streams and wait for in-flight requests up to http.shutdowntimeout
+func shutdownHttpServer(ctx context.Context, server *http.Server) {
+	shutdownCtx, cancel := context.WithTimeout(ctx, config.HttpShutdownTimeout())
+	defer cancel()
 
-	err := r.Run("0.0.0.0:" + strconv.Itoa(config.HttpServerPort()))
+	if err := httpbridge.CloseStreams(shutdownCtx); err != nil {
+		logger.Warn(ctx, "streams not closed before shutdown timeout. %s", err.Error())
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Warn(ctx, "http server not shut down gracefully. %s", err.Error())
+	}
+}
+
+// setupOpenAPI serve the OpenAPI document of routes as json and yaml with its vendored UI under /doc/, exporting it
+// when configured
+func setupOpenAPI(ctx context.Context, r *gin.Engine, routesGroup []*httpbridge.RouteGroupHttp) {
+	doc := httpbridge.OpenAPI(routesGroup)
+	docJson, err := doc.JSON()
+	if err != nil {
+		panic(err)
+	}
+	docYaml, err := doc.YAML()
 	if err != nil {
 		panic(err)
 	}
+
+	if path := config.HttpOpenAPIExport(); path != "" {
+		if err := httpbridge.ExportOpenAPI(path, routesGroup); err != nil {
+			logger.Error(ctx, "error exporting openapi document to %s. %s", path, err.Error())
+		}
+	}
+
+	r.GET("/doc", func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, "/doc/")
+	})
+	for path, asset := range map[string]string{"/doc/": "index.html", "/doc/swagger-ui.css": "swagger-ui.css", "/doc/swagger-ui-bundle.js": "swagger-ui-bundle.js"} {
+		content, err := fs.ReadFile(httpbridge.OpenAPIUI, asset)
+		if err != nil {
+			panic(err)
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(asset))
+		r.GET(path, func(c *gin.Context) {
+			c.Data(http.StatusOK, contentType, content)
+		})
+	}
+	r.GET("/doc/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", docJson)
+	})
+	r.GET("/doc/openapi.yaml", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/yaml", docYaml)
+	})
 }
-func setupDB(ctx context.Context, migrateTables []any) {
+
+func setupDB(ctx context.Context, req *RunRequest) {
 
 	gormLogLevel := loggergorm.Default.LogMode(loggergorm.Silent)
 	if config.DbShowSQL() {
 		gormLogLevel = loggergorm.Default.LogMode(loggergorm.Info)
 	}
 
-	switch config.DbType() {
-	case global.PostgresDBType:
-		clientdb, err := gorm.Open(postgres.Open(config.DbConnectionString()), &gorm.Config{
-			Logger: gormLogLevel,
-		})
+	dialect, err := db.DialectFor(config.DbType())
+	if err != nil {
+		panic(err)
+	}
 
-		if err != nil {
+	clientdb, sqlDB, err := openDB(dialect, withSchema(ctx, dialect, config.DbConnectionString(), config.DbSchema()), gormLogLevel)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := pingDB(ctx, sqlDB); err != nil {
+		panic(err)
+	}
+
+	db.SetDialect(dialect)
+	db.SetClient(clientdb)
+
+	if len(config.DbReplicas()) > 0 {
+		replicas := make([]*gorm.DB, 0, len(config.DbReplicas()))
+		for _, dsn := range config.DbReplicas() {
+			replica, _, err := openDB(dialect, withSchema(ctx, dialect, dsn, config.DbSchema()), gormLogLevel)
+			if err != nil {
+				panic(err)
+			}
+			replicas = append(replicas, replica)
+		}
+		db.SetReplicas(replicas...)
+		go db.StartReplicaHealthCheck(ctx, config.DbReplicaHealthInterval())
+	}
+
+	if req.Migrations != nil {
+		if err := db.Migrate(ctx, req.Migrations); err != nil {
 			panic(err)
 		}
+	}
+
+	migrateTables := req.MigrateTables
+	if config.DbAuditEnabled() {
+		migrateTables = append(migrateTables, &db.AuditTrail{})
+	}
+	if req.OutboxPublisher != nil {
+		migrateTables = append(migrateTables, &db.OutboxMessage{})
+	}
 
-		if config.DbSchema() != "" {
-			clientdb.Exec(fmt.Sprintf("set search_path='%s'", config.DbSchema()))
+	if migrateTables != nil {
+		for _, mt := range migrateTables {
+			logger.Debug(ctx, "migrating table %s", reflect.TypeOf(mt).String())
+			err := clientdb.AutoMigrate(mt)
+			if err != nil {
+				panic(err)
+			}
 		}
+	}
 
-		if migrateTables != nil {
-			for _, mt := range migrateTables {
-				logger.Debug(ctx, "migrating table %s", reflect.TypeOf(mt).String())
-				err := clientdb.AutoMigrate(mt)
-				if err != nil {
-					panic(err)
-				}
+	if config.TenantEnabled() && config.TenantMode() == global.TenantModeSchema {
+		db.SetTenantOpener(func(schema string) (*gorm.DB, error) {
+			dsn, err := dialect.WithSchema(config.DbConnectionString(), schema)
+			if err != nil {
+				return nil, err
+			}
+			c, _, err := openDB(dialect, dsn, gormLogLevel)
+			return c, err
+		})
+
+		for _, t := range config.TenantAllowed() {
+			if err := db.ProvisionTenant(ctx, t, req.Migrations, migrateTables); err != nil {
+				panic(err)
 			}
 		}
+	}
+
+	if err := db.RunSeeds(ctx, req.Seeds); err != nil {
+		panic(err)
+	}
+
+	go db.StartStatsReporter(ctx, config.DbStatsInterval())
+	go db.StartSoftDeletePurge(ctx, migrateTables, config.DbSoftDeleteRetention(), config.DbSoftDeletePurgeInterval())
+
+	if req.OutboxPublisher != nil && !config.DbMigrateOnly() {
+		go db.StartOutboxDispatcher(ctx, req.OutboxPublisher)
+	}
+}
+
+// withSchema bind all connections of dsn to schema when supported by dialect
+func withSchema(ctx context.Context, dialect db.Dialect, dsn, schema string) string {
+	if schema == "" {
+		return dsn
+	}
+
+	schemaDsn, err := dialect.WithSchema(dsn, schema)
+	if err != nil {
+		logger.Warn(ctx, "can not use schema %s with db type %s, using connection default. %s", schema, dialect.Name(), err.Error())
+		return dsn
+	}
+	return schemaDsn
+}
+
+// openDB open a database client applying the pool configuration, connectivity is not checked
+func openDB(dialect db.Dialect, dsn string, gormLogLevel loggergorm.Interface) (*gorm.DB, *sql.DB, error) {
+	clientdb, err := gorm.Open(dialect.Open(dsn), &gorm.Config{
+		Logger:               gormLogLevel,
+		DisableAutomaticPing: true,
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sqlDB, err := clientdb.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sqlDB.SetMaxOpenConns(config.DbPoolMaxOpen())
+	if config.DbPoolMaxIdle() > 0 {
+		sqlDB.SetMaxIdleConns(config.DbPoolMaxIdle())
+	}
+	sqlDB.SetConnMaxLifetime(config.DbPoolMaxLifetime())
+	sqlDB.SetConnMaxIdleTime(config.DbPoolMaxIdleTime())
+
+	return clientdb, sqlDB, nil
+}
+
+// pingDB check database connectivity retrying with exponential backoff
+func pingDB(ctx context.Context, sqlDB *sql.DB) error {
+	backoff := config.DbConnectBackoff()
+	retries := config.DbConnectRetries()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = sqlDB.PingContext(ctx); err == nil {
+			return nil
+		}
+
+		if attempt >= retries {
+			return fmt.Errorf("can not connect to database after %d attempts. %w", attempt+1, err)
+		}
 
-		db.SetClient(clientdb)
-	default:
-		panic(fmt.Sprintf("unsupported db type %s", config.DbType()))
+		logger.Warn(ctx, "error connecting to database, retrying in %s. %s", backoff, err.Error())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// setupStorage configure the storage used to persist uploads, RunRequest.Storage or the local one when configured
+func setupStorage(req *RunRequest) {
+	if req.Storage != nil {
+		storage.SetStorage(req.Storage)
+		return
+	}
+
+	if root := config.StorageLocalRoot(); root != "" {
+		local, err := storage.NewLocalStorage(root)
+		if err != nil {
+			panic(err)
+		}
+		storage.SetStorage(local)
 	}
 }
 
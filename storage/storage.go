@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when no object is stored under the key
+	ErrNotFound = errors.New("object not found")
+	// ErrInvalidKey is returned for empty keys and keys escaping the storage root, like ../secret
+	ErrInvalidKey = errors.New("invalid object key")
+	// ErrNotConfigured is returned by the package functions when no storage is set
+	ErrNotConfigured = errors.New("storage not configured")
+)
+
+var storage Storage
+
+// Object describe a stored object
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+}
+
+// Storage persist uploads and other binary objects by key, keys use / as separator whatever the backend
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (*Object, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// SetStorage configure the storage used by the package functions
+func SetStorage(s Storage) {
+	storage = s
+}
+
+// Default return the configured storage, nil when there is none
+func Default() Storage {
+	return storage
+}
+
+// Put store r under key using the configured storage
+func Put(ctx context.Context, key string, r io.Reader, contentType string) (*Object, error) {
+	if storage == nil {
+		return nil, ErrNotConfigured
+	}
+	return storage.Put(ctx, key, r, contentType)
+}
+
+// Open read the object stored under key using the configured storage, it must be closed by the caller
+func Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if storage == nil {
+		return nil, ErrNotConfigured
+	}
+	return storage.Open(ctx, key)
+}
+
+// Delete remove the object stored under key using the configured storage, missing objects are ignored
+func Delete(ctx context.Context, key string) error {
+	if storage == nil {
+		return ErrNotConfigured
+	}
+	return storage.Delete(ctx, key)
+}
+
+// NewKey return a random key under prefix keeping the extension of filename, client filenames should not be used as
+// keys since they may collide or carry unsafe characters
+func NewKey(prefix, filename string) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	ext := strings.ToLower(path.Ext(path.Base(strings.ReplaceAll(filename, "\\", "/"))))
+	if len(ext) > 16 || strings.ContainsAny(ext, " ?#%") {
+		ext = ""
+	}
+	return path.Join(prefix, hex.EncodeToString(b)+ext)
+}
+
+// cleanKey return key cleaned, or ErrInvalidKey when it is empty or escapes the root
+func cleanKey(key string) (string, error) {
+	key = path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))[1:]
+	if key == "" || key == "." {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
@@ -0,0 +1,104 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/rlanhellas/aruna/config"
+	"github.com/rlanhellas/aruna/global"
+)
+
+const (
+	ResolverHeader    = "header"
+	ResolverClaim     = "claim"
+	ResolverSubdomain = "subdomain"
+)
+
+var (
+	ErrTenantNotResolved = errors.New("tenant could not be resolved from request")
+	ErrUnknownTenant     = errors.New("unknown tenant")
+	ErrTenantMismatch    = errors.New("tenant does not match the tenant of the token")
+)
+
+// tenantRegex tenant ids are used as schema names, so only safe identifiers are accepted
+var tenantRegex = regexp.MustCompile(`^[A-Za-z0-9_]{1,63}$`)
+
+// WithTenant return a context bound to tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, global.TenantID, tenant)
+}
+
+// FromContext return the tenant bound to ctx, empty when there is none
+func FromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(global.TenantID).(string); ok {
+		return tenant
+	}
+	return ""
+}
+
+// Resolve find the tenant of the request using the configured resolver (header, claim or subdomain). claims must be
+// the claims of a token already checked by security.ValidateJwt, the claim resolver fails when they are nil. With the
+// header and subdomain resolvers, requests carrying claims are bound to the tenant claim: a missing tenant defaults to
+// it and any other one fails with ErrTenantMismatch
+func Resolve(r *http.Request, claims map[string]any) (string, error) {
+	var tenant string
+	switch config.TenantResolver() {
+	case ResolverHeader:
+		tenant = r.Header.Get(config.TenantHeader())
+	case ResolverClaim:
+		if claims == nil {
+			return "", ErrTenantNotResolved
+		}
+		tenant, _ = claims[config.TenantClaim()].(string)
+	case ResolverSubdomain:
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if parts := strings.Split(host, "."); len(parts) > 2 {
+			tenant = parts[0]
+		}
+	default:
+		return "", fmt.Errorf("unsupported tenant resolver %s", config.TenantResolver())
+	}
+
+	// a valid token of one tenant must not be used to reach another one
+	if claims != nil && config.TenantResolver() != ResolverClaim {
+		claimed, _ := claims[config.TenantClaim()].(string)
+		if tenant == "" {
+			tenant = claimed
+		}
+		if tenant != claimed {
+			return "", ErrTenantMismatch
+		}
+	}
+
+	if tenant == "" {
+		return "", ErrTenantNotResolved
+	}
+
+	if !Allowed(tenant) {
+		return "", ErrUnknownTenant
+	}
+
+	return tenant, nil
+}
+
+// Allowed return whether tenant is a valid identifier present in the allow-list
+func Allowed(tenant string) bool {
+	if !tenantRegex.MatchString(tenant) {
+		return false
+	}
+
+	for _, t := range config.TenantAllowed() {
+		if t == tenant {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rlanhellas/aruna/global"
+	"github.com/spf13/viper"
+)
+
+func TestResolveBindsTokensToTheirTenant(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver string
+		host     string
+		header   string
+		claims   map[string]any
+		expected string
+		err      error
+	}{
+		{"header without token", ResolverHeader, "api.example.com", "b", nil, "b", nil},
+		{"header matching claim", ResolverHeader, "api.example.com", "a", map[string]any{"tenant": "a"}, "a", nil},
+		{"header of another tenant", ResolverHeader, "api.example.com", "b", map[string]any{"tenant": "a"}, "", ErrTenantMismatch},
+		{"header defaults to claim", ResolverHeader, "api.example.com", "", map[string]any{"tenant": "a"}, "a", nil},
+		{"token without tenant claim", ResolverHeader, "api.example.com", "a", map[string]any{"sub": "ana"}, "", ErrTenantMismatch},
+		{"subdomain of another tenant", ResolverSubdomain, "b.api.example.com", "", map[string]any{"tenant": "a"}, "", ErrTenantMismatch},
+		{"claim", ResolverClaim, "api.example.com", "b", map[string]any{"tenant": "a"}, "a", nil},
+		{"missing tenant", ResolverHeader, "api.example.com", "", nil, "", ErrTenantNotResolved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			viper.Set(global.TenantResolver, tt.resolver)
+			viper.Set(global.TenantAllowed, []string{"a", "b"})
+
+			r := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/items", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Tenant-ID", tt.header)
+			}
+			tenant, err := Resolve(r, tt.claims)
+			if tenant != tt.expected || !errors.Is(err, tt.err) {
+				t.Fatalf("tenant %q, error %v, expected %q, %v", tenant, err, tt.expected, tt.err)
+			}
+		})
+	}
+}